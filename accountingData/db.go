@@ -1,11 +1,10 @@
 package accountingData
 
 import (
-	"errors"
 	"fmt"
 	"log"
 	"reflect"
-	"regexp"
+	"strings"
 	"time"
 
 	"gopkg.in/gorp.v1"
@@ -15,41 +14,41 @@ func AddTables(db *gorp.DbMap) {
 	db.AddTableWithName(AccountingData{}, AccountingDataTable).SetKeys(true, "id")
 }
 
+// Find accounting data booked with one of the doc numbers. Numbers are
+// normalised so B6 finds bookings with range B and number 006. A doc
+// number which can't be parsed returns a ParseError.
 func FindAccountingDataByDocNumbers(db *gorp.DbMap, docNumbers []string) ([]AccountingData, error) {
-
-	filters := `
-		WHERE (
-			accountingData.doc_number=?
-			AND accountingData.doc_number_range=?
-		)
-	`
-	for x := 1; x < len(docNumbers); x++ {
-		q := `
-			OR(
-				accountingData.doc_number=?
-				AND accountingData.doc_number_range=?
-			)
-		`
-		filters = fmt.Sprintf("%v %v", filters, q)
+	if len(docNumbers) == 0 {
+		return []AccountingData{}, nil
 	}
 
-	q := Q(`
-		SELECT *
-		FROM %v as accountingData
-		%v
-	`, AccountingDataTable, filters)
-
-	flatDocNumbers := []string{}
+	filters := []string{}
+	params := []interface{}{}
 	for _, n := range docNumbers {
-		rang, number, err := SplitDocNumber(n)
+		p, err := ParseDocNumber(n)
 		if err != nil {
-			return []AccountingData{}, nil
+			return []AccountingData{}, err
+		}
+
+		filter := `(
+			TRIM(LEADING '0' FROM accountingData.doc_number)=?
+			AND accountingData.doc_number_range=?`
+		params = append(params, strings.TrimLeft(p.Number, "0"), p.Range)
+		if p.Year != 0 {
+			filter += " AND YEAR(accountingData.doc_date)=?"
+			params = append(params, p.Year)
 		}
-		flatDocNumbers = append(flatDocNumbers, number, rang)
+		filters = append(filters, filter+")")
 	}
 
+	q := Q(`
+		SELECT *
+		FROM %v as accountingData
+		WHERE %v
+	`, AccountingDataTable, strings.Join(filters, " OR "))
+
 	l := []AccountingData{}
-	if _, err := db.Select(&l, q, IfaceSlice(flatDocNumbers)...); err != nil {
+	if _, err := db.Select(&l, q, params...); err != nil {
 		return []AccountingData{}, err
 	}
 
@@ -75,6 +74,46 @@ func FindAccountingDataByAccountNumber(db *gorp.DbMap, accountNumber int, from t
 	return l, nil
 }
 
+// Find accounting data for many account periods with one query
+func FindAccountingDataByAccountPeriods(db *gorp.DbMap, periods []AccountPeriod) ([]AccountingData, error) {
+	if len(periods) == 0 {
+		return []AccountingData{}, nil
+	}
+
+	filters := []string{}
+	params := []interface{}{}
+	for _, p := range periods {
+		filters = append(filters, `(
+			(doc_date BETWEEN ? AND ?)
+			AND
+			(credit_account=? OR debit_account=?)
+		)`)
+		params = append(params, p.From, p.To, p.AccountNumber, p.AccountNumber)
+	}
+
+	q := Q(`
+		SELECT *
+		FROM %v as accountingData
+		WHERE %v
+	`, AccountingDataTable, strings.Join(filters, " OR "))
+
+	l := []AccountingData{}
+	if _, err := db.Select(&l, q, params...); err != nil {
+		return []AccountingData{}, err
+	}
+
+	return l, nil
+}
+
+// Check if the accounting data was booked on the account during the period
+func (p AccountPeriod) Match(a AccountingData) bool {
+	if a.CreditAccount != p.AccountNumber && a.DebitAccount != p.AccountNumber {
+		return false
+	}
+
+	return !a.DocDate.Before(p.From) && !a.DocDate.After(p.To)
+}
+
 // Make []"any type" to []interface{}
 func IfaceSlice(slice interface{}) []interface{} {
 	s := reflect.ValueOf(slice)
@@ -91,31 +130,14 @@ func IfaceSlice(slice interface{}) []interface{} {
 	return ret
 }
 
+// Split a doc number into range and number, see ParseDocNumber
 func SplitDocNumber(docNumber string) (string, string, error) {
-	reStr := "([[:alpha:]]*)(\\d+)"
-	re, err := regexp.Compile(reStr)
+	p, err := ParseDocNumber(docNumber)
 	if err != nil {
 		return "", "", err
 	}
-	results := re.FindStringSubmatch(docNumber)
-	l := len(results)
-	if (l != 2) && (l != 3) {
-		err := errors.New("Invalid docnumber!")
-		return "", "", err
-	}
-
-	rang := ""
-	number := ""
-
-	switch l {
-	case 2:
-		number = results[1]
-	case 3:
-		rang = results[1]
-		number = results[2]
-	}
 
-	return rang, number, nil
+	return p.Range, p.Number, nil
 }
 
 func Q(q string, p ...interface{}) string {
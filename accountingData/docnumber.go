@@ -0,0 +1,168 @@
+package accountingData
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Formats of doc numbers tried in order. Every format is a regular
+	// expression with the named group number and the optional groups
+	// range and year.
+	DefaultDocNumberFormats = []string{
+		// B6, 123
+		`^(?P<range>[[:alpha:]]*)(?P<number>\d+)$`,
+		// RE-2016-0042
+		`^(?P<range>[[:alpha:]][[:alnum:]]*)[-/ ](?P<year>\d{4})[-/ ](?P<number>\d+)$`,
+		// K1-42, B-6
+		`^(?P<range>[[:alpha:]][[:alnum:]]*)[-/ ](?P<number>\d+)$`,
+	}
+
+	docNumberFormats = mustCompileFormats(DefaultDocNumberFormats)
+)
+
+// Returned if a doc number matches none of the formats
+type ParseError struct {
+	Number string
+	Reason string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("Invalid docnumber! %q %v", e.Number, e.Reason)
+}
+
+// Doc number split into its parts. The range is upper case and the number
+// has no leading zeros so B6 and b006 are the same doc number.
+type ParsedDocNumber struct {
+	Range  string `json:"range"`
+	Year   int    `json:"year"`
+	Number string `json:"number"`
+}
+
+// Replace the formats of doc numbers, see DefaultDocNumberFormats
+func SetDocNumberFormats(formats ...string) error {
+	l, err := compileFormats(formats)
+	if err != nil {
+		return err
+	}
+
+	docNumberFormats = l
+	return nil
+}
+
+func compileFormats(formats []string) ([]*regexp.Regexp, error) {
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no doc number format")
+	}
+
+	l := []*regexp.Regexp{}
+	for _, f := range formats {
+		re, err := regexp.Compile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		hasNumber := false
+		for _, n := range re.SubexpNames() {
+			switch n {
+			case "number":
+				hasNumber = true
+			case "", "range", "year":
+			default:
+				return nil, fmt.Errorf("unknown group %v in doc number format %v", n, f)
+			}
+		}
+		if !hasNumber {
+			return nil, fmt.Errorf("doc number format %v has no group number", f)
+		}
+
+		l = append(l, re)
+	}
+
+	return l, nil
+}
+
+func mustCompileFormats(formats []string) []*regexp.Regexp {
+	l, err := compileFormats(formats)
+	if err != nil {
+		panic(err)
+	}
+
+	return l
+}
+
+// Parse a doc number with the first matching format
+func ParseDocNumber(docNumber string) (ParsedDocNumber, error) {
+	s := strings.TrimSpace(docNumber)
+	if s == "" {
+		return ParsedDocNumber{}, ParseError{docNumber, "is empty"}
+	}
+
+	for _, re := range docNumberFormats {
+		m := re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+
+		p := ParsedDocNumber{}
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "range":
+				p.Range = strings.ToUpper(m[i])
+			case "year":
+				if m[i] == "" {
+					continue
+				}
+				y, err := strconv.Atoi(m[i])
+				if err != nil {
+					return ParsedDocNumber{}, ParseError{docNumber, "has an invalid year"}
+				}
+				p.Year = y
+			case "number":
+				p.Number = trimZeros(m[i])
+			}
+		}
+
+		if p.Number == "" {
+			return ParsedDocNumber{}, ParseError{docNumber, "has no number"}
+		}
+
+		return p, nil
+	}
+
+	return ParsedDocNumber{}, ParseError{docNumber, "matches no format"}
+}
+
+// Normalised form of the doc number, e.g. B6 or RE-2016-42
+func (p ParsedDocNumber) String() string {
+	if p.Year != 0 {
+		return fmt.Sprintf("%v-%v-%v", p.Range, p.Year, p.Number)
+	}
+
+	return p.Range + p.Number
+}
+
+// Check if the accounting data was booked with the doc number
+func (p ParsedDocNumber) Match(a AccountingData) bool {
+	if !strings.EqualFold(a.DocNumberRange, p.Range) {
+		return false
+	}
+
+	if trimZeros(a.DocNumber) != p.Number {
+		return false
+	}
+
+	return p.Year == 0 || a.DocDate.Year() == p.Year
+}
+
+// Remove leading zeros but keep a single zero
+func trimZeros(s string) string {
+	r := strings.TrimLeft(strings.TrimSpace(s), "0")
+	if r == "" && s != "" {
+		return "0"
+	}
+
+	return r
+}
@@ -0,0 +1,84 @@
+package accountingData
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseDocNumber(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect ParsedDocNumber
+	}{
+		{"B6", ParsedDocNumber{Range: "B", Number: "6"}},
+		{"b006", ParsedDocNumber{Range: "B", Number: "6"}},
+		{" 987 ", ParsedDocNumber{Number: "987"}},
+		{"RE-2016-0042", ParsedDocNumber{Range: "RE", Year: 2016, Number: "42"}},
+		{"K1-42", ParsedDocNumber{Range: "K1", Number: "42"}},
+		{"B000", ParsedDocNumber{Range: "B", Number: "0"}},
+	}
+
+	for _, test := range tests {
+		r, err := ParseDocNumber(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != test.expect {
+			t.Fatalf("Expect %v was %v", test.expect, r)
+		}
+	}
+}
+
+func Test_ParseDocNumber_Fail(t *testing.T) {
+	for _, in := range []string{"", "BB", "B6x", "RE-16-1"} {
+		_, err := ParseDocNumber(in)
+		if _, ok := err.(ParseError); !ok {
+			t.Fatalf("Expect ParseError for %q was %v", in, err)
+		}
+	}
+}
+
+func Test_ParsedDocNumber_Match(t *testing.T) {
+	d := time.Date(2016, time.May, 1, 0, 0, 0, 0, time.UTC)
+	a := AccountingData{DocDate: d, DocNumberRange: "B", DocNumber: "006"}
+
+	p, _ := ParseDocNumber("B6")
+	if !p.Match(a) {
+		t.Fatalf("Expect %v to match %v", p, a)
+	}
+
+	a = AccountingData{DocDate: d, DocNumberRange: "RE", DocNumber: "42"}
+	p, _ = ParseDocNumber("RE-2016-0042")
+	if !p.Match(a) {
+		t.Fatalf("Expect %v to match %v", p, a)
+	}
+
+	p, _ = ParseDocNumber("RE-2015-0042")
+	if p.Match(a) {
+		t.Fatalf("Expect %v not to match %v", p, a)
+	}
+}
+
+func Test_SetDocNumberFormats(t *testing.T) {
+	defer SetDocNumberFormats(DefaultDocNumberFormats...)
+
+	if err := SetDocNumberFormats(`^(?P<range>\d+)$`); err == nil {
+		t.Fatal("Expect error for format without number")
+	}
+
+	if err := SetDocNumberFormats(`^(?P<range>[A-Z]+)\.(?P<number>\d+)$`); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParseDocNumber("B.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Range != "B" || p.Number != "7" {
+		t.Fatalf("Expect B 7 was %v", p)
+	}
+
+	if _, err := ParseDocNumber("B7"); err == nil {
+		t.Fatal("Expect error for B7")
+	}
+}
@@ -22,3 +22,9 @@ type AccountingData struct {
 	AmountPostedEuro float64   `db:"amount_posted_euro" json:"amount_posted_euro"`
 	Currency         string    `db:"currency" json:"currency"`
 }
+
+type AccountPeriod struct {
+	AccountNumber int
+	From          time.Time
+	To            time.Time
+}
@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+// Report about gaps and duplicates of doc numbers and bookings without
+// doc. The query parameter year limits the report to one year.
+func ReadReportHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	year := 0
+	if tmp := ginCtx.Query("year"); tmp != "" {
+		var err error
+		year, err = strconv.Atoi(tmp)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	r, err := CreateReport(db, year)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,43 @@
+package audit
+
+import "github.com/tochti/docMa-handler/accountingData"
+
+// Where the numbers of a sequence come from
+const (
+	SourceDocNumbers     = "doc_numbers"
+	SourceAccountingData = "accounting_data"
+)
+
+type (
+	// Missing numbers from From to To, both included
+	Gap struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}
+
+	// Numbers of one range in one year
+	Sequence struct {
+		Source string `json:"source"`
+		Range  string `json:"range"`
+		Year   int    `json:"year"`
+		First  int    `json:"first"`
+		Last   int    `json:"last"`
+		Count  int    `json:"count"`
+		Gaps   []Gap  `json:"gaps"`
+	}
+
+	// Number attached to more than one doc
+	Duplicate struct {
+		Number string  `json:"number"`
+		DocIDs []int64 `json:"doc_ids"`
+	}
+
+	Report struct {
+		Sequences  []Sequence  `json:"sequences"`
+		Duplicates []Duplicate `json:"duplicates"`
+		// Bookings whose doc number isn't attached to any doc
+		Unattached []accountingData.AccountingData `json:"unattached"`
+		// Numbers which can't be split into range and number
+		Invalid []string `json:"invalid"`
+	}
+)
@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/docs"
+	"gopkg.in/gorp.v1"
+)
+
+// Doc number together with the dates of its doc
+type docNumber struct {
+	DocID         int64     `db:"doc_id"`
+	Number        string    `db:"number"`
+	DateOfReceipt time.Time `db:"date_of_receipt"`
+	DateOfScan    time.Time `db:"date_of_scan"`
+}
+
+// The year of a doc number is the year of receipt of its doc, or the year
+// of scan if the date of receipt is missing
+func (n docNumber) year() int {
+	if n.DateOfReceipt.IsZero() {
+		return n.DateOfScan.Year()
+	}
+
+	return n.DateOfReceipt.Year()
+}
+
+type sequenceKey struct {
+	source string
+	rang   string
+	year   int
+}
+
+// Check the doc numbers and the accounting data for gaps, duplicates and
+// bookings without doc. If year isn't 0 only numbers of the year are checked.
+func CreateReport(db *gorp.DbMap, year int) (Report, error) {
+	numbers := []docNumber{}
+	q := Q(`
+	SELECT dn.doc_id, dn.number, d.date_of_receipt, d.date_of_scan
+	FROM %v as dn, %v as d
+	WHERE d.id = dn.doc_id`, docs.DocNumbersTable, docs.DocsTable)
+	if _, err := db.Select(&numbers, q); err != nil {
+		return Report{}, err
+	}
+
+	bookings := []accountingData.AccountingData{}
+	q = Q("SELECT * FROM %v ORDER BY id", accountingData.AccountingDataTable)
+	if _, err := db.Select(&bookings, q); err != nil {
+		return Report{}, err
+	}
+
+	return buildReport(numbers, bookings, year), nil
+}
+
+func buildReport(numbers []docNumber, bookings []accountingData.AccountingData, year int) Report {
+	r := Report{
+		Sequences:  []Sequence{},
+		Duplicates: []Duplicate{},
+		Unattached: []accountingData.AccountingData{},
+		Invalid:    []string{},
+	}
+
+	values := map[sequenceKey]map[int]bool{}
+	add := func(k sequenceKey, v int) {
+		if values[k] == nil {
+			values[k] = map[int]bool{}
+		}
+		values[k][v] = true
+	}
+
+	// Doc numbers of all docs, the year is ignored for duplicates and
+	// attached numbers because a booking may be in another year than the
+	// receipt of its doc. Numbers are compared in their normalised form so
+	// B6 and B006 are the same number.
+	docIDs := map[string][]int64{}
+	attached := map[string][]accountingData.ParsedDocNumber{}
+	invalid := map[string]bool{}
+	for _, n := range numbers {
+		p, err := accountingData.ParseDocNumber(n.Number)
+		if err != nil {
+			invalid[n.Number] = true
+			continue
+		}
+
+		docIDs[p.String()] = appendUnique(docIDs[p.String()], n.DocID)
+		k := bookingKey(p.Range, p.Number)
+		attached[k] = append(attached[k], p)
+
+		v, err := strconv.Atoi(p.Number)
+		if err != nil {
+			invalid[n.Number] = true
+			continue
+		}
+
+		y := p.Year
+		if y == 0 {
+			y = n.year()
+		}
+		if year != 0 && y != year {
+			continue
+		}
+		add(sequenceKey{SourceDocNumbers, p.Range, y}, v)
+	}
+
+	for _, b := range bookings {
+		y := b.DocDate.Year()
+		if year != 0 && y != year {
+			continue
+		}
+
+		if b.DocNumber == "" {
+			continue
+		}
+
+		if !isAttached(attached[bookingKey(b.DocNumberRange, b.DocNumber)], b) {
+			r.Unattached = append(r.Unattached, b)
+		}
+
+		v, err := strconv.Atoi(b.DocNumber)
+		if err != nil {
+			invalid[b.DocNumberRange+b.DocNumber] = true
+			continue
+		}
+		add(sequenceKey{SourceAccountingData, strings.ToUpper(b.DocNumberRange), y}, v)
+	}
+
+	for k, v := range values {
+		r.Sequences = append(r.Sequences, newSequence(k, v))
+	}
+	sort.Sort(bySequence(r.Sequences))
+
+	for number, ids := range docIDs {
+		if len(ids) > 1 {
+			r.Duplicates = append(r.Duplicates, Duplicate{
+				Number: number,
+				DocIDs: ids,
+			})
+		}
+	}
+	sort.Sort(byNumber(r.Duplicates))
+
+	for n := range invalid {
+		r.Invalid = append(r.Invalid, n)
+	}
+	sort.Strings(r.Invalid)
+
+	return r
+}
+
+func newSequence(k sequenceKey, values map[int]bool) Sequence {
+	l := []int{}
+	for v := range values {
+		l = append(l, v)
+	}
+	sort.Ints(l)
+
+	s := Sequence{
+		Source: k.source,
+		Range:  k.rang,
+		Year:   k.year,
+		First:  l[0],
+		Last:   l[len(l)-1],
+		Count:  len(l),
+		Gaps:   []Gap{},
+	}
+
+	for i := 1; i < len(l); i++ {
+		if l[i]-l[i-1] > 1 {
+			s.Gaps = append(s.Gaps, Gap{From: l[i-1] + 1, To: l[i] - 1})
+		}
+	}
+
+	return s
+}
+
+func bookingKey(rang, number string) string {
+	n := strings.TrimLeft(number, "0")
+	return strings.ToUpper(rang) + "/" + n
+}
+
+func isAttached(l []accountingData.ParsedDocNumber, b accountingData.AccountingData) bool {
+	for _, p := range l {
+		if p.Match(b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendUnique(l []int64, id int64) []int64 {
+	for _, e := range l {
+		if e == id {
+			return l
+		}
+	}
+
+	return append(l, id)
+}
+
+type bySequence []Sequence
+
+func (s bySequence) Len() int      { return len(s) }
+func (s bySequence) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySequence) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if a.Source != b.Source {
+		return a.Source > b.Source
+	}
+	if a.Range != b.Range {
+		return a.Range < b.Range
+	}
+	return a.Year < b.Year
+}
+
+type byNumber []Duplicate
+
+func (d byNumber) Len() int           { return len(d) }
+func (d byNumber) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byNumber) Less(i, j int) bool { return d[i].Number < d[j].Number }
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
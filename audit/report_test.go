@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+)
+
+func Test_buildReport(t *testing.T) {
+	d2015 := time.Date(2015, time.December, 1, 0, 0, 0, 0, time.UTC)
+	d2016 := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	numbers := []docNumber{
+		{DocID: 1, Number: "B1", DateOfReceipt: d2016},
+		{DocID: 2, Number: "B2", DateOfReceipt: d2016},
+		{DocID: 3, Number: "B5", DateOfScan: d2016},
+		{DocID: 4, Number: "B005", DateOfReceipt: d2016},
+		{DocID: 5, Number: "B9", DateOfReceipt: d2015},
+		{DocID: 6, Number: "xx", DateOfReceipt: d2016},
+	}
+	bookings := []accountingData.AccountingData{
+		{ID: 1, DocDate: d2016, DocNumberRange: "B", DocNumber: "1"},
+		{ID: 2, DocDate: d2016, DocNumberRange: "B", DocNumber: "2"},
+		{ID: 3, DocDate: d2016, DocNumberRange: "B", DocNumber: "3"},
+		{ID: 4, DocDate: d2016, DocNumberRange: "B", DocNumber: "5"},
+		// Without doc number, so there is nothing to attach
+		{ID: 5, DocDate: d2016},
+	}
+
+	r := buildReport(numbers, bookings, 0)
+
+	expect := Report{
+		Sequences: []Sequence{
+			{Source: SourceDocNumbers, Range: "B", Year: 2015, First: 9, Last: 9, Count: 1, Gaps: []Gap{}},
+			{Source: SourceDocNumbers, Range: "B", Year: 2016, First: 1, Last: 5, Count: 3, Gaps: []Gap{{3, 4}}},
+			{Source: SourceAccountingData, Range: "B", Year: 2016, First: 1, Last: 5, Count: 4, Gaps: []Gap{{4, 4}}},
+		},
+		Duplicates: []Duplicate{{Number: "B5", DocIDs: []int64{3, 4}}},
+		Unattached: []accountingData.AccountingData{bookings[2]},
+		Invalid:    []string{"xx"},
+	}
+
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	r = buildReport(numbers, bookings, 2015)
+	if len(r.Sequences) != 1 || r.Sequences[0].Year != 2015 || len(r.Unattached) != 0 {
+		t.Fatalf("Unexpected report %v", r)
+	}
+}
+
+func Test_ReadReportHandler(t *testing.T) {
+	db := common.InitTestDB(t, docs.AddTables, labels.AddTables, accountingData.AddTables)
+
+	d := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	err := db.Insert(
+		&docs.Doc{ID: 1, Name: "a.pdf", Barcode: "1", DateOfReceipt: d},
+		&docs.Doc{ID: 2, Name: "b.pdf", Barcode: "2", DateOfReceipt: d},
+		&docs.DocNumber{DocID: 1, Number: "B1"},
+		&docs.DocNumber{DocID: 2, Number: "B3"},
+		&accountingData.AccountingData{ID: 1, DocDate: d, DocNumberRange: "B", DocNumber: "1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/", gumwrap.Gorp(ReadReportHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/?year=2016", "")
+
+	expect := Report{
+		Sequences: []Sequence{
+			{Source: SourceDocNumbers, Range: "B", Year: 2016, First: 1, Last: 3, Count: 2, Gaps: []Gap{{2, 2}}},
+			{Source: SourceAccountingData, Range: "B", Year: 2016, First: 1, Last: 1, Count: 1, Gaps: []Gap{}},
+		},
+		Duplicates: []Duplicate{},
+		Unattached: []accountingData.AccountingData{},
+		Invalid:    []string{},
+	}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package barcodes encodes Code128 barcodes and reads them from scanned
+// images.
+package barcodes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrNoBarcode = errors.New("no barcode found")
+)
+
+// Widths of bars and spaces of all Code128 symbols, starting with a bar.
+// Every symbol is 11 modules wide, the stop symbol 13.
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213",
+	"122312", "132212", "221213", "221312", "231212", "112232", "122132",
+	"122231", "113222", "123122", "123221", "223211", "221132", "221231",
+	"213212", "223112", "312131", "311222", "321122", "321221", "312212",
+	"322112", "322211", "212123", "212321", "232121", "111323", "131123",
+	"131321", "112313", "132113", "132311", "211313", "231113", "231311",
+	"112133", "112331", "132131", "113123", "113321", "133121", "313121",
+	"211331", "231131", "213113", "213311", "213131", "311123", "311321",
+	"331121", "312113", "312311", "332111", "314111", "221411", "431111",
+	"111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114",
+	"413111", "241112", "134111", "111242", "121142", "121241", "114212",
+	"124112", "124211", "411212", "421112", "421211", "212141", "214121",
+	"412121", "111143", "111341", "131141", "114113", "114311", "411113",
+	"411311", "113141", "114131", "311141", "411131", "211412", "211214",
+	"211232", "2331112",
+}
+
+const (
+	code128Shift  = 98
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128CodeA  = 101
+	code128StartA = 103
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// Encode the text with code set B. The result are the widths in modules
+// of the alternating bars and spaces, starting with a bar.
+func Code128(text string) ([]int, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty barcode")
+	}
+
+	symbols := []int{code128StartB}
+	sum := code128StartB
+	for i, c := range text {
+		if c < 32 || c > 127 {
+			return nil, fmt.Errorf("character %q can't be encoded with Code128 B", c)
+		}
+
+		v := int(c) - 32
+		symbols = append(symbols, v)
+		sum += v * (i + 1)
+	}
+	symbols = append(symbols, sum%103, code128Stop)
+
+	widths := []int{}
+	for _, s := range symbols {
+		for _, w := range code128Patterns[s] {
+			widths = append(widths, int(w-'0'))
+		}
+	}
+
+	return widths, nil
+}
+
+// Decode the widths of bars and spaces, starting with the bar of the start
+// symbol and ending with the stop symbol. The widths can have any scale,
+// every symbol is matched with the pattern closest to its widths.
+func Decode128(widths []float64) (string, error) {
+	if len(widths) < 6*3+7 || (len(widths)-7)%6 != 0 {
+		return "", ErrNoBarcode
+	}
+
+	symbols := []int{}
+	for i := 0; i+7 < len(widths); i += 6 {
+		s, ok := matchSymbol(widths[i : i+6])
+		if !ok {
+			return "", ErrNoBarcode
+		}
+		symbols = append(symbols, s)
+	}
+	if !isStop(widths[len(widths)-7:]) {
+		return "", ErrNoBarcode
+	}
+
+	return decodeSymbols(symbols)
+}
+
+// Text of the symbols from the start symbol to the checksum
+func decodeSymbols(symbols []int) (string, error) {
+	start := symbols[0]
+	if start < code128StartA || start > code128StartC || len(symbols) < 3 {
+		return "", ErrNoBarcode
+	}
+
+	sum := start
+	for i, s := range symbols[1 : len(symbols)-1] {
+		sum += s * (i + 1)
+	}
+	if sum%103 != symbols[len(symbols)-1] {
+		return "", ErrNoBarcode
+	}
+
+	set := start
+	text := []byte{}
+	shift := false
+	for _, s := range symbols[1 : len(symbols)-1] {
+		cur := set
+		if shift {
+			cur = code128StartA + code128StartB - set
+			shift = false
+		}
+
+		switch {
+		case cur == code128StartC && s < 100:
+			text = append(text, byte('0'+s/10), byte('0'+s%10))
+		case cur != code128StartC && s < 96:
+			if cur == code128StartA && s >= 64 {
+				text = append(text, byte(s-64))
+			} else {
+				text = append(text, byte(s+32))
+			}
+		case s == code128Shift && cur != code128StartC:
+			shift = true
+		case s == code128CodeC:
+			set = code128StartC
+		case s == code128CodeB && cur != code128StartB:
+			set = code128StartB
+		case s == code128CodeA && cur != code128StartA:
+			set = code128StartA
+		default:
+			// Function codes carry no text
+		}
+	}
+
+	if len(text) == 0 {
+		return "", ErrNoBarcode
+	}
+
+	return string(text), nil
+}
+
+// Symbol whose pattern is closest to the widths of 6 bars and spaces
+func matchSymbol(widths []float64) (int, bool) {
+	best, bestErr := -1, 0.0
+	for i, p := range code128Patterns[:code128Stop] {
+		e := patternError(widths, p, 11)
+		if best < 0 || e < bestErr {
+			best, bestErr = i, e
+		}
+	}
+
+	return best, bestErr < maxPatternError
+}
+
+func isStop(widths []float64) bool {
+	return patternError(widths, code128Patterns[code128Stop], 13) < maxPatternError
+}
+
+// Difference in modules between the widths scaled to the size of the
+// pattern and the pattern. The widths of neighbouring bar and space pairs
+// are compared, they stay the same if ink bleeds into the spaces. The
+// single widths are weighted less and only break ties.
+const maxPatternError = 2.0
+
+func patternError(widths []float64, pattern string, modules float64) float64 {
+	total := 0.0
+	for _, w := range widths {
+		total += w
+	}
+	if total <= 0 {
+		return modules
+	}
+
+	scaled := make([]float64, len(widths))
+	for i, w := range widths {
+		scaled[i] = w*modules/total - float64(pattern[i]-'0')
+	}
+
+	e := 0.0
+	for i := range scaled {
+		e += math.Abs(scaled[i]) / 4
+		if i > 0 {
+			e += math.Abs(scaled[i-1] + scaled[i])
+		}
+	}
+
+	return e
+}
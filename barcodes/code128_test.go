@@ -0,0 +1,107 @@
+package barcodes
+
+import "testing"
+
+func Test_Code128Patterns(t *testing.T) {
+	seen := map[string]bool{}
+	for i, p := range code128Patterns {
+		sum := 0
+		for _, w := range p {
+			sum += int(w - '0')
+		}
+
+		expect := 11
+		if i == code128Stop {
+			expect = 13
+		}
+		if sum != expect {
+			t.Fatalf("Expect %v modules for symbol %v was %v", expect, i, sum)
+		}
+
+		if seen[p] {
+			t.Fatalf("Pattern of symbol %v is not unique", i)
+		}
+		seen[p] = true
+	}
+}
+
+func Test_Code128(t *testing.T) {
+	widths, err := Code128("PJJ123C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start, 7 characters, checksum and stop
+	modules := 0
+	for _, w := range widths {
+		modules += w
+	}
+	if expect := 11*9 + 13; modules != expect {
+		t.Fatalf("Expect %v was %v", expect, modules)
+	}
+
+	// Checksum (104 + 48*1 + 42*2 + 42*3 + 17*4 + 18*5 + 19*6 + 35*7) % 103
+	checksum := code128Patterns[55]
+	r := ""
+	for _, w := range widths[len(widths)-13 : len(widths)-7] {
+		r += string(rune('0' + w))
+	}
+	if r != checksum {
+		t.Fatalf("Expect %v was %v", checksum, r)
+	}
+
+	if _, err := Code128("Müller"); err == nil {
+		t.Fatal("Expect error for ü")
+	}
+}
+
+func Test_Decode128(t *testing.T) {
+	widths, err := Code128("PJJ123C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scaled and with bars which bleed into the spaces
+	scaled := []float64{}
+	for i, w := range widths {
+		f := float64(w) * 3.3
+		if i%2 == 0 {
+			f += 0.8
+		} else {
+			f -= 0.8
+		}
+		scaled = append(scaled, f)
+	}
+
+	text, err := Decode128(scaled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "PJJ123C" {
+		t.Fatalf("Expect PJJ123C was %v", text)
+	}
+
+	// Wrong checksum
+	scaled[len(scaled)-13], scaled[len(scaled)-12] = scaled[len(scaled)-12], scaled[len(scaled)-13]
+	if _, err := Decode128(scaled); err != ErrNoBarcode {
+		t.Fatalf("Expect %v was %v", ErrNoBarcode, err)
+	}
+}
+
+func Test_DecodeSymbolsCodeSets(t *testing.T) {
+	// Start C, 12, 34, Code B, "A", checksum
+	symbols := []int{code128StartC, 12, 34, code128CodeB, 33}
+	sum := code128StartC
+	for i, s := range symbols[1:] {
+		sum += s * (i + 1)
+	}
+	symbols = append(symbols, sum%103)
+
+	text, err := decodeSymbols(symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "1234A" {
+		t.Fatalf("Expect 1234A was %v", text)
+	}
+}
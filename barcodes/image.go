@@ -0,0 +1,155 @@
+package barcodes
+
+import (
+	"image"
+	"image/color"
+)
+
+var (
+	// Lines of the image which are scanned for a barcode in each
+	// direction. More lines find damaged barcodes but take longer.
+	ScanLines = 200
+	// Difference between the darkest and the brightest pixel of a line
+	// below which the line is skipped
+	minContrast = 64
+)
+
+// Find a Code128 barcode in the image. Rows and columns are scanned in
+// both directions, so the page can be rotated by a multiple of 90
+// degrees.
+func ScanImage(img image.Image) (string, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return "", ErrNoBarcode
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		gray = toGray(img)
+	}
+
+	line := make([]uint8, h)
+	for _, i := range scanOrder(h) {
+		row := gray.Pix[i*gray.Stride : i*gray.Stride+w]
+		if text, err := scanLine(row); err == nil {
+			return text, nil
+		}
+	}
+	for _, i := range scanOrder(w) {
+		for y := 0; y < h; y++ {
+			line[y] = gray.Pix[y*gray.Stride+i]
+		}
+		if text, err := scanLine(line); err == nil {
+			return text, nil
+		}
+	}
+
+	return "", ErrNoBarcode
+}
+
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gray.Set(x-b.Min.X, y-b.Min.Y, color.GrayModel.Convert(img.At(x, y)))
+		}
+	}
+
+	return gray
+}
+
+// Indexes of the lines to scan, spread evenly over n
+func scanOrder(n int) []int {
+	step := n / ScanLines
+	if step < 1 {
+		step = 1
+	}
+
+	r := []int{}
+	for i := step / 2; i < n; i += step {
+		r = append(r, i)
+	}
+
+	return r
+}
+
+// Decode the first barcode found in the pixels of one line, read forwards
+// or backwards
+func scanLine(pix []uint8) (string, error) {
+	lo, hi := uint8(255), uint8(0)
+	for _, p := range pix {
+		if p < lo {
+			lo = p
+		}
+		if p > hi {
+			hi = p
+		}
+	}
+	if int(hi)-int(lo) < minContrast {
+		return "", ErrNoBarcode
+	}
+	threshold := (int(lo) + int(hi)) / 2
+
+	// Widths of alternating bars and spaces, starting with a bar
+	runs := []float64{}
+	dark := false
+	for _, p := range pix {
+		d := int(p) < threshold
+		if d != dark {
+			runs = append(runs, 0)
+			dark = d
+		}
+		if len(runs) > 0 {
+			runs[len(runs)-1]++
+		}
+	}
+	if dark {
+		// The line ends in a bar, so there is no quiet zone after it
+		runs = runs[:len(runs)-1]
+	}
+
+	if text, err := scanRuns(runs); err == nil {
+		return text, nil
+	}
+
+	reversed := make([]float64, len(runs))
+	for i, r := range runs {
+		reversed[len(runs)-1-i] = r
+	}
+	// The reversed runs start with the space after the last bar
+	if len(reversed) > 0 {
+		reversed = reversed[1:]
+	}
+
+	return scanRuns(reversed)
+}
+
+// Try every bar which looks like a start symbol. The runs alternate
+// between bars and spaces, starting with a bar.
+func scanRuns(runs []float64) (string, error) {
+	for i := 0; i+6*3+7 <= len(runs); i += 2 {
+		s, ok := matchSymbol(runs[i : i+6])
+		if !ok || s < code128StartA || s > code128StartC {
+			continue
+		}
+
+		symbols := []int{s}
+		for j := i + 6; j+7 <= len(runs); j += 6 {
+			if isStop(runs[j : j+7]) {
+				if text, err := decodeSymbols(symbols); err == nil {
+					return text, nil
+				}
+			}
+
+			s, ok := matchSymbol(runs[j : j+6])
+			if !ok {
+				break
+			}
+			symbols = append(symbols, s)
+		}
+	}
+
+	return "", ErrNoBarcode
+}
@@ -0,0 +1,89 @@
+package barcodes
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// White page with the barcode drawn with the given pixels per module and
+// some noise like a scanner adds
+func barcodeImage(t *testing.T, text string, module int) *image.Gray {
+	widths, err := Code128(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modules := 0
+	for _, w := range widths {
+		modules += w
+	}
+
+	w, h := modules*module+200, 300
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(220 + r.Intn(30))
+	}
+
+	x := 100
+	for i, bw := range widths {
+		if i%2 == 0 {
+			for y := 100; y < 200; y++ {
+				for dx := 0; dx < bw*module; dx++ {
+					img.SetGray(x+dx, y, color.Gray{uint8(10 + r.Intn(40))})
+				}
+			}
+		}
+		x += bw * module
+	}
+
+	return img
+}
+
+// Rotate the image by 90 degrees
+func rotate(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	r := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r.SetGray(b.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+
+	return r
+}
+
+func Test_ScanImage(t *testing.T) {
+	img := barcodeImage(t, "7KQ2MX9A", 4)
+
+	rotated := img
+	for i := 0; i < 4; i++ {
+		text, err := ScanImage(rotated)
+		if err != nil {
+			t.Fatalf("Rotated %v times: %v", i, err)
+		}
+		if text != "7KQ2MX9A" {
+			t.Fatalf("Expect 7KQ2MX9A was %v", text)
+		}
+
+		rotated = rotate(rotated)
+	}
+}
+
+func Test_ScanImageNoBarcode(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := 50; y < 60; y++ {
+		for x := 50; x < 350; x++ {
+			img.Pix[y*img.Stride+x] = 0
+		}
+	}
+
+	if _, err := ScanImage(img); err != ErrNoBarcode {
+		t.Fatalf("Expect %v was %v", ErrNoBarcode, err)
+	}
+}
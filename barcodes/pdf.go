@@ -0,0 +1,249 @@
+package barcodes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// Dictionary of a stream object with up to two levels of nested
+	// dictionaries, e.g. /DecodeParms
+	streamDictRe = regexp.MustCompile(`<<((?:[^<>]|<<(?:[^<>]|<<[^<>]*>>)*>>)*)>>\s*stream\r?\n`)
+	endstreamRe  = regexp.MustCompile(`\r?\n?endstream`)
+	imageRe      = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	filterRe     = regexp.MustCompile(`/Filter\s*\[?\s*/(\w+)\s*\]?`)
+	colorSpaceRe = regexp.MustCompile(`/ColorSpace\s*/(\w+)`)
+	imageMaskRe  = regexp.MustCompile(`/ImageMask\s*true`)
+	decodeRe     = regexp.MustCompile(`/Decode\s*\[\s*1(?:\.0*)?\s+0(?:\.0*)?\s*\]`)
+)
+
+// Find a Code128 barcode in the images of the PDF, e.g. a scanned cover
+// sheet. JPEG and deflated images are decoded, images in other formats
+// like CCITT or JBIG2 are skipped.
+func ReadPDF(pdf []byte) (string, error) {
+	for _, img := range PDFImages(pdf) {
+		if text, err := ScanImage(img); err == nil {
+			return text, nil
+		}
+	}
+
+	return "", ErrNoBarcode
+}
+
+// Decode all images of the PDF which are in a supported format
+func PDFImages(pdf []byte) []image.Image {
+	images := []image.Image{}
+	for _, m := range streamDictRe.FindAllSubmatchIndex(pdf, -1) {
+		dict := pdf[m[2]:m[3]]
+		if !imageRe.Match(dict) {
+			continue
+		}
+
+		data := pdf[m[1]:]
+		if l, ok := intEntry(dict, "Length"); ok && l <= len(data) {
+			data = data[:l]
+		} else if e := endstreamRe.FindIndex(data); e != nil {
+			data = data[:e[0]]
+		} else {
+			continue
+		}
+
+		if img, err := decodeImage(dict, data); err == nil {
+			images = append(images, img)
+		}
+	}
+
+	return images
+}
+
+func decodeImage(dict, data []byte) (image.Image, error) {
+	filter := ""
+	if m := filterRe.FindSubmatch(dict); m != nil {
+		filter = string(m[1])
+	}
+
+	switch filter {
+	case "DCTDecode":
+		return jpeg.Decode(bytes.NewReader(data))
+	case "FlateDecode":
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		raw, err := ioutil.ReadAll(r)
+		if err != nil && len(raw) == 0 {
+			return nil, err
+		}
+
+		return rawImage(dict, raw)
+	case "":
+		return rawImage(dict, data)
+	default:
+		return nil, ErrNoBarcode
+	}
+}
+
+// Image from uncompressed samples with 1 or 8 bits per component
+func rawImage(dict, raw []byte) (image.Image, error) {
+	w, _ := intEntry(dict, "Width")
+	h, _ := intEntry(dict, "Height")
+	bits, ok := intEntry(dict, "BitsPerComponent")
+	mask := imageMaskRe.Match(dict)
+	if mask {
+		bits = 1
+	} else if !ok {
+		bits = 8
+	}
+	if w <= 0 || h <= 0 || (bits != 1 && bits != 8) {
+		return nil, ErrNoBarcode
+	}
+
+	colors := 1
+	if m := colorSpaceRe.FindSubmatch(dict); m != nil {
+		switch string(m[1]) {
+		case "DeviceRGB", "CalRGB":
+			colors = 3
+		case "DeviceCMYK":
+			colors = 4
+		}
+	} else if bits == 8 && len(raw) >= w*h*3 {
+		// ICC based or indexed color space given by reference
+		colors = len(raw) / (w * h)
+	}
+	if bits == 1 {
+		colors = 1
+	}
+
+	stride := (w*colors*bits + 7) / 8
+	if p, _ := intEntry(dict, "Predictor"); p >= 10 {
+		var err error
+		raw, err = unpredict(raw, stride, (colors*bits+7)/8)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(raw) < stride*h {
+		return nil, ErrNoBarcode
+	}
+
+	// Samples which are 0 are black, for image masks too because they
+	// paint them with the fill color
+	invert := decodeRe.Match(dict)
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		row := raw[y*stride : (y+1)*stride]
+		for x := 0; x < w; x++ {
+			var v uint8
+			switch {
+			case bits == 1:
+				v = 0
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					v = 255
+				}
+			case colors == 1:
+				v = row[x]
+			case colors == 3:
+				p := row[x*3 : x*3+3]
+				v = uint8((299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000)
+			case colors == 4:
+				p := row[x*4 : x*4+4]
+				ink := (300*int(p[0])+590*int(p[1])+110*int(p[2]))/1000 + int(p[3])
+				if ink > 255 {
+					ink = 255
+				}
+				v = uint8(255 - ink)
+			default:
+				v = row[x*colors]
+			}
+
+			if invert {
+				v = 255 - v
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+
+	return img, nil
+}
+
+// Reverse the PNG filters used by the predictors 10 to 15. Every row
+// starts with a byte giving its filter type.
+func unpredict(raw []byte, stride, bpp int) ([]byte, error) {
+	rows := len(raw) / (stride + 1)
+	out := make([]byte, rows*stride)
+	prev := make([]byte, stride)
+	for y := 0; y < rows; y++ {
+		in := raw[y*(stride+1) : (y+1)*(stride+1)]
+		row := out[y*stride : (y+1)*stride]
+		copy(row, in[1:])
+
+		for x := range row {
+			var left, upLeft byte
+			if x >= bpp {
+				left = row[x-bpp]
+				upLeft = prev[x-bpp]
+			}
+			up := prev[x]
+
+			switch in[0] {
+			case 0:
+			case 1:
+				row[x] += left
+			case 2:
+				row[x] += up
+			case 3:
+				row[x] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[x] += paeth(left, up, upLeft)
+			default:
+				return nil, ErrNoBarcode
+			}
+		}
+		prev = row
+	}
+
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func absInt(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Direct integer value of the entry, references like 5 0 R are ignored
+func intEntry(dict []byte, name string) (int, bool) {
+	re := regexp.MustCompile(`/` + name + `\s+(\d+)\b(\s+\d+\s+R)?`)
+	m := re.FindSubmatch(dict)
+	if m == nil || m[2] != nil {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return 0, false
+	}
+
+	return i, true
+}
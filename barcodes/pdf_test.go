@@ -0,0 +1,120 @@
+package barcodes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// PDF with the image as the only page content, like a scanner writes it
+func imagePDF(dict string, data []byte) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	b.WriteString("2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n")
+	b.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /XObject << /Im0 4 0 R >> >> /Contents 5 0 R >>\nendobj\n")
+	fmt.Fprintf(b, "4 0 obj\n<< /Type /XObject /Subtype /Image %v /Length %v >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream\nendobj\n")
+	content := "q 595 0 0 842 0 0 cm /Im0 Do Q"
+	fmt.Fprintf(b, "5 0 obj\n<< /Length %v >>\nstream\n%v\nendstream\nendobj\n", len(content), content)
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	return b.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	b := &bytes.Buffer{}
+	w := zlib.NewWriter(b)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func Test_ReadPDFFlate(t *testing.T) {
+	img := barcodeImage(t, "ABC23", 3)
+	b := img.Bounds()
+	dict := fmt.Sprintf("/Width %v /Height %v /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", b.Dx(), b.Dy())
+
+	text, err := ReadPDF(imagePDF(dict, deflate(t, img.Pix)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ABC23" {
+		t.Fatalf("Expect ABC23 was %v", text)
+	}
+}
+
+func Test_ReadPDFFlateBitonalPredictor(t *testing.T) {
+	img := barcodeImage(t, "ABC23", 3)
+	b := img.Bounds()
+	stride := (b.Dx() + 7) / 8
+
+	// 1 bit per pixel with the PNG up filter on every row
+	raw := []byte{}
+	prev := make([]byte, stride)
+	for y := 0; y < b.Dy(); y++ {
+		row := make([]byte, stride)
+		for x := 0; x < b.Dx(); x++ {
+			if img.GrayAt(x, y).Y > 128 {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		raw = append(raw, 2)
+		for i := range row {
+			raw = append(raw, row[i]-prev[i])
+		}
+		prev = row
+	}
+
+	dict := fmt.Sprintf("/Width %v /Height %v /ColorSpace /DeviceGray /BitsPerComponent 1 /Filter /FlateDecode /DecodeParms << /Predictor 15 /Columns %v >>", b.Dx(), b.Dy(), b.Dx())
+
+	text, err := ReadPDF(imagePDF(dict, deflate(t, raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ABC23" {
+		t.Fatalf("Expect ABC23 was %v", text)
+	}
+}
+
+func Test_ReadPDFJPEG(t *testing.T) {
+	gray := barcodeImage(t, "X9Y8Z7", 4)
+	b := gray.Bounds()
+	img := image.NewRGBA(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			img.Set(x, y, color.RGBA{gray.GrayAt(x, y).Y, gray.GrayAt(x, y).Y, 255, 255})
+		}
+	}
+
+	data := &bytes.Buffer{}
+	if err := jpeg.Encode(data, img, &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	dict := fmt.Sprintf("/Width %v /Height %v /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", b.Dx(), b.Dy())
+
+	text, err := ReadPDF(imagePDF(dict, data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "X9Y8Z7" {
+		t.Fatalf("Expect X9Y8Z7 was %v", text)
+	}
+}
+
+func Test_ReadPDFWithoutImages(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Length 4 >>\nstream\nq Q\n\nendstream\nendobj\n")
+	if _, err := ReadPDF(pdf); err != ErrNoBarcode {
+		t.Fatalf("Expect %v was %v", ErrNoBarcode, err)
+	}
+}
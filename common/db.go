@@ -21,7 +21,7 @@ func InitMySQL() *gorp.DbMap {
 	db := &gorp.DbMap{
 		Db: sqlDB,
 		Dialect: gorp.MySQLDialect{
-			"InnonDB",
+			"InnoDB",
 			"UTF8",
 		},
 	}
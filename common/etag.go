@@ -0,0 +1,39 @@
+package common
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidIfMatch = errors.New("If-Match must be an ETag like \"3\"")
+)
+
+// Version of a row as ETag
+func SetETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// Version expected by the If-Match header, 0 if the header is missing or
+// is * so every version matches
+func ReadIfMatch(c *gin.Context) (int64, error) {
+	h := strings.TrimSpace(c.Request.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return 0, nil
+	}
+
+	s, err := strconv.Unquote(strings.TrimPrefix(h, "W/"))
+	if err != nil {
+		return 0, ErrInvalidIfMatch
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, ErrInvalidIfMatch
+	}
+
+	return v, nil
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_ReadIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version int64
+		err     error
+	}{
+		{"", 0, nil},
+		{"*", 0, nil},
+		{`"3"`, 3, nil},
+		{`W/"12"`, 12, nil},
+		{`3`, 0, ErrInvalidIfMatch},
+		{`"0"`, 0, ErrInvalidIfMatch},
+		{`"abc"`, 0, ErrInvalidIfMatch},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("PATCH", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", c.header)
+
+		v, err := ReadIfMatch(&gin.Context{Request: req})
+		if v != c.version || err != c.err {
+			t.Fatalf("Expect %v, %v for %q was %v, %v", c.version, c.err, c.header, v, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// String which is stored as NULL if it is empty, e.g. for unique columns
+// which are optional. MySQL allows any number of NULLs in a unique index.
+type NullString string
+
+func (s NullString) Value() (driver.Value, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	return string(s), nil
+}
+
+func (s *NullString) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = NullString(v)
+	case []byte:
+		*s = NullString(v)
+	default:
+		return fmt.Errorf("can't scan %T into NullString", v)
+	}
+
+	return nil
+}
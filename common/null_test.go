@@ -0,0 +1,26 @@
+package common
+
+import "testing"
+
+func Test_NullString(t *testing.T) {
+	v, err := NullString("").Value()
+	if err != nil || v != nil {
+		t.Fatalf("Expect nil was %v %v", v, err)
+	}
+
+	v, err = NullString("A12").Value()
+	if err != nil || v != "A12" {
+		t.Fatalf("Expect A12 was %v %v", v, err)
+	}
+
+	s := NullString("old")
+	if err := s.Scan(nil); err != nil || s != "" {
+		t.Fatalf("Expect empty string was %q %v", s, err)
+	}
+	if err := s.Scan([]byte("B7")); err != nil || s != "B7" {
+		t.Fatalf("Expect B7 was %q %v", s, err)
+	}
+	if err := s.Scan(3); err == nil {
+		t.Fatal("Expect error for int")
+	}
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	TotalCountHeader = "X-Total-Count"
+
+	ErrInvalidLimit  = errors.New("limit must be a positive number")
+	ErrInvalidOffset = errors.New("offset must be a positive number")
+	ErrInvalidOrder  = errors.New("order must be asc or desc")
+)
+
+// MySQL needs a limit if a offset is set, this is the biggest possible limit
+const maxLimit = "18446744073709551615"
+
+type (
+	// Page selects a slice of a list. A limit of 0 means no limit.
+	Page struct {
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+		Sort   string `json:"sort"`
+		Order  string `json:"order"`
+	}
+
+	// Maps the sort fields a client can request to SQL columns
+	SortFields map[string]string
+)
+
+// Read the query parameters limit, offset, sort and order
+func ReadPage(c *gin.Context) (Page, error) {
+	p := Page{
+		Sort:  c.Query("sort"),
+		Order: strings.ToLower(c.Query("order")),
+	}
+
+	if tmp := c.Query("limit"); tmp != "" {
+		i, err := strconv.Atoi(tmp)
+		if err != nil || i < 0 {
+			return Page{}, ErrInvalidLimit
+		}
+		p.Limit = i
+	}
+
+	if tmp := c.Query("offset"); tmp != "" {
+		i, err := strconv.Atoi(tmp)
+		if err != nil || i < 0 {
+			return Page{}, ErrInvalidOffset
+		}
+		p.Offset = i
+	}
+
+	if p.Order != "" && p.Order != "asc" && p.Order != "desc" {
+		return Page{}, ErrInvalidOrder
+	}
+
+	return p, nil
+}
+
+// Create the ORDER BY and LIMIT clause. The tie breaker column is appended
+// to the order to get a stable order if the sort column isn't unique.
+func (p Page) SQL(fields SortFields, tieBreaker string) (string, error) {
+	dir := "ASC"
+	switch strings.ToLower(p.Order) {
+	case "", "asc":
+	case "desc":
+		dir = "DESC"
+	default:
+		return "", ErrInvalidOrder
+	}
+
+	order := fmt.Sprintf("ORDER BY %v %v", tieBreaker, dir)
+	if p.Sort != "" {
+		col, ok := fields[p.Sort]
+		if !ok {
+			return "", fmt.Errorf("cannot sort by %v", p.Sort)
+		}
+		if col != tieBreaker {
+			order = fmt.Sprintf("ORDER BY %v %v, %v %v", col, dir, tieBreaker, dir)
+		}
+	}
+
+	if p.Limit < 0 {
+		return "", ErrInvalidLimit
+	}
+	if p.Offset < 0 {
+		return "", ErrInvalidOffset
+	}
+
+	switch {
+	case p.Limit > 0:
+		order = fmt.Sprintf("%v LIMIT %v OFFSET %v", order, p.Limit, p.Offset)
+	case p.Offset > 0:
+		order = fmt.Sprintf("%v LIMIT %v OFFSET %v", order, maxLimit, p.Offset)
+	}
+
+	return order, nil
+}
+
+// Set the number of all list elements without limit and offset
+func SetTotalCount(c *gin.Context, n int64) {
+	c.Header(TotalCountHeader, strconv.FormatInt(n, 10))
+}
@@ -0,0 +1,78 @@
+package common
+
+import "testing"
+
+var testSortFields = SortFields{
+	"id":   "docs.id",
+	"name": "docs.name",
+}
+
+func Test_PageSQL_Default(t *testing.T) {
+	r, err := Page{}.SQL(testSortFields, "docs.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "ORDER BY docs.id ASC"
+	if r != expect {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_PageSQL(t *testing.T) {
+	p := Page{
+		Limit:  10,
+		Offset: 20,
+		Sort:   "name",
+		Order:  "desc",
+	}
+
+	r, err := p.SQL(testSortFields, "docs.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "ORDER BY docs.name DESC, docs.id DESC LIMIT 10 OFFSET 20"
+	if r != expect {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_PageSQL_OnlyOffset(t *testing.T) {
+	p := Page{
+		Offset: 20,
+		Sort:   "id",
+	}
+
+	r, err := p.SQL(testSortFields, "docs.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "ORDER BY docs.id ASC LIMIT 18446744073709551615 OFFSET 20"
+	if r != expect {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_PageSQL_UnknownSortField(t *testing.T) {
+	p := Page{
+		Sort: "docs.name; DROP TABLE docs",
+	}
+
+	_, err := p.SQL(testSortFields, "docs.id")
+	if err == nil {
+		t.Fatal("Expect error was nil")
+	}
+}
+
+func Test_PageSQL_InvalidOrder(t *testing.T) {
+	p := Page{
+		Order: "up",
+	}
+
+	_, err := p.SQL(testSortFields, "docs.id")
+	if err != ErrInvalidOrder {
+		t.Fatalf("Expect %v was %v", ErrInvalidOrder, err)
+	}
+}
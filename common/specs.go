@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/tochti/docMa-handler/pdfTools"
 )
 
 var (
@@ -15,9 +16,19 @@ type (
 		Public          string `envconfig:"PUBLIC"`
 		PDFViewerPublic string `envconfig:"PDFVIEWER_PUBLIC"`
 		Files           string `envconfig:"FILES"`
+		// Directory watched for new scans, failed scans are moved to
+		// DeadLetter. HotFolderLabels is a comma separated list of label
+		// names attached to every scan.
+		HotFolder       string `envconfig:"HOT_FOLDER"`
+		DeadLetter      string `envconfig:"DEAD_LETTER"`
+		HotFolderLabels string `envconfig:"HOT_FOLDER_LABELS"`
+		// Maildir with mails whose PDF attachments are imported
+		Maildir string `envconfig:"MAILDIR"`
 	}
 )
 
+// Read the specs from DOCMA_FILES, DOCMA_HOT_FOLDER, ... A missing qpdf
+// is reported here, splitting and merging docs fails without it.
 func LoadSpecs() Specs {
 	specs := Specs{}
 	err := envconfig.Process(AppName, &specs)
@@ -25,5 +36,9 @@ func LoadSpecs() Specs {
 		log.Fatal(err)
 	}
 
+	if err := pdfTools.Check(); err != nil {
+		log.Printf("warning: %v", err)
+	}
+
 	return specs
 }
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// The app runs behind a proxy which authenticates the user and passes
+	// the user name in this header
+	UserHeader = "X-Docma-User"
+
+	ErrNoUser = errors.New("missing user header " + UserHeader)
+)
+
+func ReadUser(c *gin.Context) string {
+	return c.Request.Header.Get(UserHeader)
+}
+
+// Like ReadUser but fail if the request has no user, e.g. for data which
+// belongs to the user
+func RequireUser(c *gin.Context) (string, error) {
+	user := ReadUser(c)
+	if user == "" {
+		return "", ErrNoUser
+	}
+
+	return user, nil
+}
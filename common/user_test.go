@@ -0,0 +1,32 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_RequireUser(t *testing.T) {
+	cases := []struct {
+		header string
+		user   string
+		err    error
+	}{
+		{"karl", "karl", nil},
+		{"", "", ErrNoUser},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(UserHeader, c.header)
+
+		user, err := RequireUser(&gin.Context{Request: req})
+		if user != c.user || err != c.err {
+			t.Fatalf("Expect %v, %v for %q was %v, %v", c.user, c.err, c.header, user, err)
+		}
+	}
+}
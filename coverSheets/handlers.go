@@ -0,0 +1,53 @@
+package coverSheets
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/docMa-handler/valid"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+// Create placeholders and return the printable PDF, with the query
+// parameter format=json the created sheets are returned instead
+func CreateCoverSheetsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	form := CoverSheetsForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	sheets, err := CreateCoverSheets(db, form, time.Now())
+	if err != nil {
+		if _, ok := err.(labels.GroupConflictError); ok {
+			gumrest.ErrorResponse(ginCtx, http.StatusConflict, err)
+			return
+		}
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(sheets)))
+
+	if ginCtx.Query("format") == "json" {
+		ginCtx.JSON(http.StatusCreated, sheets)
+		return
+	}
+
+	b, err := RenderPDF(sheets)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ginCtx.Data(http.StatusCreated, "application/pdf", b)
+}
@@ -0,0 +1,27 @@
+// Package coverSheets creates placeholder docs with new barcodes and
+// renders printable cover sheets for them. The barcodes are drawn as
+// Code128, QR codes are not supported.
+package coverSheets
+
+import (
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+type (
+	// Number of sheets and what is attached to every placeholder. With
+	// DocNumbers set every placeholder gets the next doc number, from the
+	// range RangeID or from the doc number proposal if RangeID is 0.
+	CoverSheetsForm struct {
+		Count      int     `json:"count" valid:"min=1,max=500"`
+		LabelIDs   []int64 `json:"label_ids"`
+		DocNumbers bool    `json:"doc_numbers"`
+		RangeID    int64   `json:"range_id"`
+	}
+
+	CoverSheet struct {
+		Doc       docs.Doc       `json:"doc"`
+		DocNumber string         `json:"doc_number"`
+		Labels    []labels.Label `json:"labels"`
+	}
+)
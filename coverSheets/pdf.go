@@ -0,0 +1,106 @@
+package coverSheets
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 in points
+const (
+	pageWidth  = 595
+	pageHeight = 842
+)
+
+// Minimal PDF document with one page per content stream. Text uses the
+// built-in font Helvetica so nothing has to be embedded.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *pdfWriter) object(body string) int {
+	w.offsets = append(w.offsets, w.buf.Len())
+	id := len(w.offsets)
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+	return id
+}
+
+func (w *pdfWriter) stream(content string) int {
+	return w.object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+}
+
+func renderPDF(pages []string) []byte {
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.4\n")
+
+	// Object ids are fixed: 1 catalog, 2 pages, 3 font, then per page the
+	// content and the page
+	w.object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := []string{}
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	w.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(pages)))
+
+	w.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for _, p := range pages {
+		content := w.stream(p)
+		w.object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, content))
+	}
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, o := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.offsets)+1, xref)
+
+	return w.buf.Bytes()
+}
+
+// Content stream helpers
+
+func pdfBars(widths []int, x, y, module, height float64) string {
+	b := bytes.Buffer{}
+	b.WriteString("0 g\n")
+	for i, w := range widths {
+		if i%2 == 0 {
+			fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f re f\n", x, y, float64(w)*module, height)
+		}
+		x += float64(w) * module
+	}
+
+	return b.String()
+}
+
+func pdfText(text string, x, y, size float64) string {
+	return fmt.Sprintf("BT /F1 %.0f Tf %.2f %.2f Td (%s) Tj ET\n", size, x, y, pdfEscape(text))
+}
+
+// Escape a string for a PDF literal, characters outside of Latin-1 are
+// replaced because Helvetica is used with WinAnsiEncoding
+func pdfEscape(s string) string {
+	b := bytes.Buffer{}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package coverSheets
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_RenderPDF(t *testing.T) {
+	sheets := []CoverSheet{
+		{
+			Doc:       docs.Doc{Barcode: "AB23CD45"},
+			DocNumber: "B7",
+			Labels:    []labels.Label{{Name: "Miete (Büro)"}},
+		},
+		{
+			Doc: docs.Doc{Barcode: "XY67ZW89"},
+		},
+	}
+
+	b, err := RenderPDF(sheets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(b, []byte("%PDF-1.4")) {
+		t.Fatal("Expect PDF header")
+	}
+
+	// Every offset of the xref table points to its object
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(b)
+	if m == nil {
+		t.Fatal("Expect startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(b[xref:], -1)
+	if len(entries) != 7 {
+		t.Fatalf("Expect %v objects was %v", 7, len(entries))
+	}
+	for i, e := range entries {
+		o, _ := strconv.Atoi(string(e[1]))
+		prefix := []byte(fmt.Sprintf("%d 0 obj", i+1))
+		if !bytes.HasPrefix(b[o:], prefix) {
+			t.Fatalf("Expect object %v at offset %v", i+1, o)
+		}
+	}
+
+	if !bytes.Contains(b, []byte(`(Label: Miete \(B\374ro\))`)) {
+		t.Fatal("Expect escaped label")
+	}
+
+	barcode, err := docs.MarkerBarcodeReader{}.ReadBarcode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if barcode != "AB23CD45" {
+		t.Fatalf("Expect %v was %v", "AB23CD45", barcode)
+	}
+}
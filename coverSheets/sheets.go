@@ -0,0 +1,189 @@
+package coverSheets
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tochti/docMa-handler/barcodes"
+	"github.com/tochti/docMa-handler/docNumberProposal"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	BarcodeLength = 8
+	// Without 0, O, 1 and I which are easily mixed up when typed
+	barcodeChars = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// Attempts to find a barcode which isn't used yet
+	barcodeAttempts = 10
+)
+
+// Create a placeholder doc with a new barcode for every sheet in one
+// transaction
+func CreateCoverSheets(db *gorp.DbMap, form CoverSheetsForm, now time.Time) ([]CoverSheet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return []CoverSheet{}, err
+	}
+
+	r, err := createCoverSheets(tx, form, now)
+	if err != nil {
+		tx.Rollback()
+		return []CoverSheet{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return []CoverSheet{}, err
+	}
+
+	return r, nil
+}
+
+func createCoverSheets(tx gorp.SqlExecutor, form CoverSheetsForm, now time.Time) ([]CoverSheet, error) {
+	labelList, err := readLabels(tx, form.LabelIDs)
+	if err != nil {
+		return []CoverSheet{}, err
+	}
+
+	r := []CoverSheet{}
+	for i := 0; i < form.Count; i++ {
+		doc, err := createPlaceholder(tx)
+		if err != nil {
+			return []CoverSheet{}, err
+		}
+
+		sheet := CoverSheet{
+			Doc:    doc,
+			Labels: labelList,
+		}
+
+		for _, l := range labelList {
+			if err := labels.CheckExclusiveGroup(tx, doc.ID, l.ID); err != nil {
+				return []CoverSheet{}, err
+			}
+			if err := tx.Insert(&docs.DocsLabels{DocID: doc.ID, LabelID: l.ID}); err != nil {
+				return []CoverSheet{}, err
+			}
+		}
+
+		if form.DocNumbers {
+			n, err := reserveDocNumber(tx, form.RangeID, now)
+			if err != nil {
+				return []CoverSheet{}, err
+			}
+			if err := tx.Insert(&docs.DocNumber{DocID: doc.ID, Number: n}); err != nil {
+				return []CoverSheet{}, err
+			}
+			sheet.DocNumber = n
+		}
+
+		r = append(r, sheet)
+	}
+
+	return r, nil
+}
+
+func createPlaceholder(tx gorp.SqlExecutor) (docs.Doc, error) {
+	for i := 0; i < barcodeAttempts; i++ {
+		barcode, err := newBarcode()
+		if err != nil {
+			return docs.Doc{}, err
+		}
+
+		doc, err := docs.CreatePlaceholder(tx, barcode)
+		if err == docs.ErrBarcodeUsed {
+			continue
+		}
+
+		return doc, err
+	}
+
+	return docs.Doc{}, fmt.Errorf("no unused barcode found")
+}
+
+func reserveDocNumber(tx gorp.SqlExecutor, rangeID int64, now time.Time) (string, error) {
+	if rangeID != 0 {
+		p, err := docNumberProposal.ReserveRangeTx(tx, rangeID, now)
+		if err != nil {
+			return "", err
+		}
+		return p.Number, nil
+	}
+
+	v, err := docNumberProposal.ReserveTx(tx)
+	if err != nil {
+		return "", err
+	}
+
+	return v.Value, nil
+}
+
+func readLabels(tx gorp.SqlExecutor, ids []int64) ([]labels.Label, error) {
+	r := []labels.Label{}
+	for _, id := range ids {
+		l := labels.Label{}
+		q := fmt.Sprintf("SELECT * FROM %v WHERE id=?", labels.LabelsTable)
+		if err := tx.SelectOne(&l, q, id); err != nil {
+			return []labels.Label{}, fmt.Errorf("label %v not found", id)
+		}
+		r = append(r, l)
+	}
+
+	return r, nil
+}
+
+func newBarcode() (string, error) {
+	b := make([]byte, BarcodeLength)
+	max := big.NewInt(int64(len(barcodeChars)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = barcodeChars[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// Render one A4 page per sheet with the barcode, the barcode as text with
+// the marker read by docs.MarkerBarcodeReader, the doc number and labels.
+// The bars are read from scans by docs.ImageBarcodeReader.
+func RenderPDF(sheets []CoverSheet) ([]byte, error) {
+	pages := []string{}
+	for _, s := range sheets {
+		widths, err := barcodes.Code128(string(s.Doc.Barcode))
+		if err != nil {
+			return nil, err
+		}
+
+		modules := 0
+		for _, w := range widths {
+			modules += w
+		}
+		module := 2.0
+		x := (pageWidth - float64(modules)*module) / 2
+
+		p := pdfText("Cover sheet", 72, 760, 24)
+		p += pdfBars(widths, x, 560, module, 120)
+		p += pdfText(string(s.Doc.Barcode), x, 530, 20)
+		p += pdfText(docs.BarcodeMarker+string(s.Doc.Barcode), x, 510, 8)
+
+		y := 440.0
+		if s.DocNumber != "" {
+			p += pdfText("Doc number: "+s.DocNumber, 72, y, 14)
+			y -= 24
+		}
+		for _, l := range s.Labels {
+			p += pdfText("Label: "+l.Name, 72, y, 14)
+			y -= 20
+		}
+
+		pages = append(pages, p)
+	}
+
+	return renderPDF(pages), nil
+}
@@ -0,0 +1,83 @@
+package coverSheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/docMa-handler/docNumberProposal"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_CreateCoverSheets(t *testing.T) {
+	db := common.InitTestDB(t,
+		docs.AddTables,
+		labels.AddTables,
+		dbVars.AddTables,
+		docNumberProposal.AddTables,
+	)
+
+	err := db.Insert(
+		&labels.Label{ID: 1, Name: "Miete"},
+		&docNumberProposal.DocNumberRange{ID: 1, Prefix: "B", Value: 6, Year: 2016},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := CoverSheetsForm{
+		Count:      2,
+		LabelIDs:   []int64{1},
+		DocNumbers: true,
+		RangeID:    1,
+	}
+	now := time.Date(2016, time.May, 1, 0, 0, 0, 0, time.UTC)
+	r, err := CreateCoverSheets(db, form, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 2 {
+		t.Fatalf("Expect %v was %v", 2, len(r))
+	}
+	if r[0].DocNumber != "B7" || r[1].DocNumber != "B8" {
+		t.Fatalf("Expect B7 and B8 was %v and %v", r[0].DocNumber, r[1].DocNumber)
+	}
+	if r[0].Doc.Barcode == r[1].Doc.Barcode {
+		t.Fatal("Expect unique barcodes")
+	}
+
+	for _, s := range r {
+		doc, err := docs.FindPlaceholder(db, string(s.Doc.Barcode))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := docs.FindLabelsOfDoc(db, doc.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 1 || l[0].ID != 1 {
+			t.Fatalf("Expect label 1 was %v", l)
+		}
+	}
+}
+
+func Test_CreateCoverSheets_UnknownLabel(t *testing.T) {
+	db := common.InitTestDB(t, docs.AddTables, labels.AddTables)
+
+	form := CoverSheetsForm{Count: 1, LabelIDs: []int64{1}}
+	if _, err := CreateCoverSheets(db, form, time.Now()); err == nil {
+		t.Fatal("Expect error for unknown label")
+	}
+
+	n, err := db.SelectInt("SELECT COUNT(*) FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("Expect %v was %v", 0, n)
+	}
+}
@@ -1,12 +1,15 @@
 package docNumberProposal
 
 import (
-	"fmt"
+	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
 	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/docMa-handler/settings"
 	"github.com/tochti/docMa-handler/valid"
 	"github.com/tochti/gin-gum/gumrest"
 	"gopkg.in/gorp.v1"
@@ -16,30 +19,46 @@ var (
 	varName = "docNumberProposal"
 )
 
+func init() {
+	settings.Register(settings.Setting{
+		Name:        varName,
+		Kind:        settings.KindInt,
+		Default:     "0",
+		Description: "Last reserved doc number of the doc number proposal",
+		Volatile:    true,
+		Validate:    settings.MinInt(0),
+	})
+}
+
 func ReadDocNumberProposalHandler(ginCtx *gin.Context, db *gorp.DbMap) {
-	v, err := db.Get(dbVars.DBVar{}, varName)
+	v, err := settings.Get(db, varName)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
 	}
 
-	ginCtx.JSON(http.StatusOK, v)
+	ginCtx.JSON(http.StatusOK, dbVars.DBVar{Name: varName, Value: v.Value})
 }
 
 func NextDocNumberProposalHandler(ginCtx *gin.Context, db *gorp.DbMap) {
-	v := dbVars.DBVar{}
-	q := fmt.Sprintf("SELECT * FROM %v WHERE name=?", dbVars.DBVarsTable)
-	if err := db.SelectOne(&v, q, varName); err != nil {
+	i, err := settings.Int(db, varName)
+	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
 		return
 	}
 
-	i, err := strconv.Atoi(v.Value)
+	ginCtx.JSON(http.StatusOK, dbVars.DBVar{Name: varName, Value: strconv.Itoa(i + 1)})
+}
+
+// Reserve the next doc number, unlike NextDocNumberProposalHandler the
+// number is stored and no other request gets it again
+func ReserveDocNumberProposalHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	v, err := Reserve(db)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
 		return
 	}
 
-	v.Value = strconv.Itoa(i + 1)
 	ginCtx.JSON(http.StatusOK, v)
 }
 
@@ -55,10 +74,99 @@ func UpdateDocNumberProposalHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	if _, err := db.Update(&v); err != nil {
+	if _, err := settings.Set(db, varName, v.Value); err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, v)
+	ginCtx.JSON(http.StatusOK, dbVars.DBVar{Name: varName, Value: v.Value})
+}
+
+func ReadAllDocNumberRangesHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	l, err := ReadAllDocNumberRanges(db)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+func CreateDocNumberRangeHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	r := DocNumberRange{}
+	if err := ginCtx.BindJSON(&r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := r.Validate(); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r.ID = 0
+	if err := db.Insert(&r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, r)
+}
+
+// Propose the next number of a range without reserving it
+func NextDocNumberRangeHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadRangeID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	p, err := ProposeRange(db, id, time.Now())
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, p)
+}
+
+// Reserve the next number of a range
+func ReserveDocNumberRangeHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadRangeID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	p, err := ReserveRange(db, id, time.Now())
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, p)
+}
+
+func ReadRangeID(c *gin.Context) (int64, error) {
+	tmp := c.Params.ByName("rangeID")
+	id, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil {
+		gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func errorResponse(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+		return
+	}
+
+	gumrest.ErrorResponse(c, http.StatusInternalServerError, err)
 }
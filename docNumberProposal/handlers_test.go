@@ -3,6 +3,8 @@ package docNumberProposal
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"sync"
 	"testing"
 
 	"gopkg.in/gorp.v1"
@@ -91,6 +93,90 @@ func Test_UpdateDocNumberProposalHandler(t *testing.T) {
 
 }
 
+func Test_ReserveDocNumberProposalHandler(t *testing.T) {
+	db := initDB(t)
+
+	v := dbVars.DBVar{
+		Name:  "docNumberProposal",
+		Value: "1",
+	}
+
+	if err := db.Insert(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(ReserveDocNumberProposalHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", "")
+
+	v.Value = "2"
+	expectResp := gumtest.JSONResponse{http.StatusOK, v}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := db.Get(dbVars.DBVar{}, "docNumberProposal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmp.(*dbVars.DBVar).Value != "2" {
+		t.Fatalf("Expect %v was %v", "2", tmp.(*dbVars.DBVar).Value)
+	}
+}
+
+func Test_Reserve_Parallel(t *testing.T) {
+	db := initDB(t)
+
+	v := dbVars.DBVar{
+		Name:  "docNumberProposal",
+		Value: "0",
+	}
+
+	if err := db.Insert(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 50
+	results := make(chan string, n)
+	errs := make(chan error, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := Reserve(db)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- v.Value
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for r := range results {
+		if seen[r] {
+			t.Fatalf("Number %v was reserved twice", r)
+		}
+		seen[r] = true
+	}
+
+	for i := 1; i <= n; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Fatalf("Expect number %v to be reserved", i)
+		}
+	}
+}
+
 func initDB(t *testing.T) *gorp.DbMap {
 	return common.InitTestDB(t, dbVars.AddTables)
 }
@@ -0,0 +1,151 @@
+package docNumberProposal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	DocNumberRangesTable = "doc_number_ranges"
+
+	ErrYearlyResetPrefix = errors.New("ranges with yearly reset need a prefix")
+)
+
+type (
+	// Sequence of doc numbers with the same prefix, e.g. B1, B2, ...
+	// Value is the last reserved number, Year the year it was reserved in.
+	// With yearly reset the year is part of the number, e.g. B-2016-1.
+	DocNumberRange struct {
+		ID          int64  `db:"id" json:"id"`
+		Prefix      string `db:"prefix" json:"prefix" valid:"omitempty,alpha"`
+		Padding     int    `db:"padding" json:"padding" valid:"min=0,max=20"`
+		YearlyReset bool   `db:"yearly_reset" json:"yearly_reset"`
+		Value       int    `db:"value" json:"value" valid:"min=0"`
+		Year        int    `db:"year" json:"year"`
+	}
+
+	Proposal struct {
+		RangeID int64  `json:"range_id"`
+		Value   int    `json:"value"`
+		Number  string `json:"number"`
+	}
+)
+
+func AddTables(db *gorp.DbMap) {
+	tMap := db.AddTableWithName(DocNumberRange{}, DocNumberRangesTable).
+		SetKeys(true, "id")
+	tMap.ColMap("prefix").SetUnique(true).SetMaxSize(32)
+}
+
+// Value and year of the next number, with yearly reset the sequence starts
+// with 1 in every new year
+func (r DocNumberRange) Next(now time.Time) (int, int) {
+	year := now.Year()
+	if r.YearlyReset && r.Year != year {
+		return 1, year
+	}
+
+	return r.Value + 1, year
+}
+
+// Numbers of ranges with yearly reset repeat every year, the year keeps
+// them apart. The parser of doc numbers needs a prefix to find the year.
+func (r DocNumberRange) Validate() error {
+	if r.YearlyReset && r.Prefix == "" {
+		return ErrYearlyResetPrefix
+	}
+
+	return nil
+}
+
+// Doc number of the value, e.g. B007 for prefix B and padding 3 or
+// B-2016-007 with yearly reset
+func (r DocNumberRange) Format(value, year int) string {
+	if r.YearlyReset {
+		return fmt.Sprintf("%v-%v-%0*d", r.Prefix, year, r.Padding, value)
+	}
+
+	return fmt.Sprintf("%v%0*d", r.Prefix, r.Padding, value)
+}
+
+func (r DocNumberRange) proposal(value, year int) Proposal {
+	return Proposal{
+		RangeID: r.ID,
+		Value:   value,
+		Number:  r.Format(value, year),
+	}
+}
+
+func ReadAllDocNumberRanges(db *gorp.DbMap) ([]DocNumberRange, error) {
+	l := []DocNumberRange{}
+	q := Q("SELECT * FROM %v ORDER BY prefix", DocNumberRangesTable)
+	if _, err := db.Select(&l, q); err != nil {
+		return []DocNumberRange{}, err
+	}
+
+	return l, nil
+}
+
+func ReadDocNumberRange(db gorp.SqlExecutor, id int64) (DocNumberRange, error) {
+	r := DocNumberRange{}
+	q := Q("SELECT * FROM %v WHERE id=?", DocNumberRangesTable)
+	if err := db.SelectOne(&r, q, id); err != nil {
+		return DocNumberRange{}, err
+	}
+
+	return r, nil
+}
+
+// Propose the next number of the range without reserving it
+func ProposeRange(db *gorp.DbMap, id int64, now time.Time) (Proposal, error) {
+	r, err := ReadDocNumberRange(db, id)
+	if err != nil {
+		return Proposal{}, err
+	}
+
+	return r.proposal(r.Next(now)), nil
+}
+
+// Reserve the next number of the range. The row is locked until the
+// transaction is committed so concurrent callers never get the same number.
+func ReserveRange(db *gorp.DbMap, id int64, now time.Time) (Proposal, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Proposal{}, err
+	}
+
+	p, err := ReserveRangeTx(tx, id, now)
+	if err != nil {
+		tx.Rollback()
+		return Proposal{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Proposal{}, err
+	}
+
+	return p, nil
+}
+
+// Like ReserveRange but within the transaction of the caller
+func ReserveRangeTx(tx gorp.SqlExecutor, id int64, now time.Time) (Proposal, error) {
+	r := DocNumberRange{}
+	q := Q("SELECT * FROM %v WHERE id=? FOR UPDATE", DocNumberRangesTable)
+	if err := tx.SelectOne(&r, q, id); err != nil {
+		return Proposal{}, err
+	}
+
+	r.Value, r.Year = r.Next(now)
+	if _, err := tx.Update(&r); err != nil {
+		return Proposal{}, err
+	}
+
+	return r.proposal(r.Value, r.Year), nil
+}
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
@@ -0,0 +1,135 @@
+package docNumberProposal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+	"gopkg.in/gorp.v1"
+)
+
+func Test_DocNumberRange_Next(t *testing.T) {
+	now := time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		r     DocNumberRange
+		value int
+		year  int
+	}{
+		{DocNumberRange{Value: 6, Year: 2016}, 7, 2016},
+		{DocNumberRange{Value: 6, Year: 2015}, 7, 2016},
+		{DocNumberRange{Value: 6, Year: 2015, YearlyReset: true}, 1, 2016},
+		{DocNumberRange{Value: 6, Year: 2016, YearlyReset: true}, 7, 2016},
+	}
+
+	for _, test := range tests {
+		v, y := test.r.Next(now)
+		if v != test.value || y != test.year {
+			t.Fatalf("Expect %v %v was %v %v", test.value, test.year, v, y)
+		}
+	}
+}
+
+func Test_DocNumberRange_Format(t *testing.T) {
+	tests := []struct {
+		r      DocNumberRange
+		value  int
+		expect string
+	}{
+		{DocNumberRange{Prefix: "B"}, 6, "B6"},
+		{DocNumberRange{Prefix: "B", Padding: 3}, 6, "B006"},
+		{DocNumberRange{Padding: 2}, 123, "123"},
+		{DocNumberRange{Prefix: "B", Padding: 3, YearlyReset: true}, 6, "B-2016-006"},
+	}
+
+	for _, test := range tests {
+		if r := test.r.Format(test.value, 2016); r != test.expect {
+			t.Fatalf("Expect %v was %v", test.expect, r)
+		}
+	}
+}
+
+func Test_DocNumberRange_Format_YearlyReset(t *testing.T) {
+	r := DocNumberRange{Prefix: "B", YearlyReset: true}
+
+	p1, err := accountingData.ParseDocNumber(r.Format(1, 2016))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := accountingData.ParseDocNumber(r.Format(1, 2017))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p1.Year != 2016 || p2.Year != 2017 || p1.String() == p2.String() {
+		t.Fatalf("Expect different doc numbers was %v and %v", p1, p2)
+	}
+
+	if err := (DocNumberRange{YearlyReset: true}).Validate(); err != ErrYearlyResetPrefix {
+		t.Fatalf("Expect %v was %v", ErrYearlyResetPrefix, err)
+	}
+}
+
+func Test_CreateDocNumberRangeHandler_YearlyResetPrefix(t *testing.T) {
+	db := initRangesDB(t)
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateDocNumberRangeHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", `{"yearly_reset": true}`)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func Test_ReserveDocNumberRangeHandler(t *testing.T) {
+	db := initRangesDB(t)
+
+	year := time.Now().Year()
+	ranges := []*DocNumberRange{
+		{ID: 1, Prefix: "B", Padding: 3, Value: 6, Year: year},
+		{ID: 2, Prefix: "", Value: 41, Year: year},
+	}
+	if err := db.Insert(gumtest.IfaceSlice(ranges)...); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/:rangeID", gumwrap.Gorp(ReserveDocNumberRangeHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/1", "")
+
+	expect := Proposal{RangeID: 1, Value: 7, Number: "B007"}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ReadDocNumberRange(db, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Value != 41 {
+		t.Fatalf("Expect %v was %v", 41, other.Value)
+	}
+}
+
+func Test_NextDocNumberRangeHandler_NotFound(t *testing.T) {
+	db := initRangesDB(t)
+
+	r := gin.New()
+	r.GET("/:rangeID", gumwrap.Gorp(NextDocNumberRangeHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/1", "")
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func initRangesDB(t *testing.T) *gorp.DbMap {
+	return common.InitTestDB(t, AddTables)
+}
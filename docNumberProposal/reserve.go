@@ -0,0 +1,41 @@
+package docNumberProposal
+
+import (
+	"strconv"
+
+	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/docMa-handler/settings"
+	"gopkg.in/gorp.v1"
+)
+
+// Increment the doc number proposal and return the new value. The row is
+// locked until the transaction is committed so concurrent callers never
+// get the same number.
+func Reserve(db *gorp.DbMap) (dbVars.DBVar, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return dbVars.DBVar{}, err
+	}
+
+	v, err := ReserveTx(tx)
+	if err != nil {
+		tx.Rollback()
+		return dbVars.DBVar{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return dbVars.DBVar{}, err
+	}
+
+	return v, nil
+}
+
+// Like Reserve but within the transaction of the caller
+func ReserveTx(tx gorp.SqlExecutor) (dbVars.DBVar, error) {
+	i, err := settings.IncrementTx(tx, varName)
+	if err != nil {
+		return dbVars.DBVar{}, err
+	}
+
+	return dbVars.DBVar{Name: varName, Value: strconv.Itoa(i)}, nil
+}
@@ -0,0 +1,303 @@
+package docs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrNoLabels = errors.New("label_ids or label_names are required")
+)
+
+type (
+	// Apply all combinations of docs and labels. Labels are given by ID or
+	// by name, missing labels are created when they are attached.
+	BulkLabelsForm struct {
+		DocIDs     []int64  `json:"doc_ids" valid:"required,min=1"`
+		LabelIDs   []int64  `json:"label_ids"`
+		LabelNames []string `json:"label_names"`
+	}
+
+	BulkLabelsResult struct {
+		Attached      []DocsLabels   `json:"attached"`
+		Detached      []DocsLabels   `json:"detached"`
+		Unchanged     []DocsLabels   `json:"unchanged"`
+		CreatedLabels []labels.Label `json:"created_labels"`
+	}
+)
+
+func newBulkLabelsResult() BulkLabelsResult {
+	return BulkLabelsResult{
+		Attached:      []DocsLabels{},
+		Detached:      []DocsLabels{},
+		Unchanged:     []DocsLabels{},
+		CreatedLabels: []labels.Label{},
+	}
+}
+
+// Attach all labels to all docs in one transaction
+func AttachLabels(db *gorp.DbMap, form BulkLabelsForm) (BulkLabelsResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return BulkLabelsResult{}, err
+	}
+
+	r, err := attachLabels(tx, form)
+	if err != nil {
+		tx.Rollback()
+		return BulkLabelsResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return BulkLabelsResult{}, err
+	}
+
+	return r, nil
+}
+
+// Detach all labels from all docs in one transaction
+func DetachLabels(db *gorp.DbMap, form BulkLabelsForm) (BulkLabelsResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return BulkLabelsResult{}, err
+	}
+
+	r, err := detachLabels(tx, form)
+	if err != nil {
+		tx.Rollback()
+		return BulkLabelsResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return BulkLabelsResult{}, err
+	}
+
+	return r, nil
+}
+
+func attachLabels(tx gorp.SqlExecutor, form BulkLabelsForm) (BulkLabelsResult, error) {
+	r := newBulkLabelsResult()
+
+	if err := checkDocsExist(tx, form.DocIDs); err != nil {
+		return r, err
+	}
+
+	if err := lockDocs(tx, form.DocIDs); err != nil {
+		return r, err
+	}
+
+	labelIDs, created, err := resolveLabels(tx, form, true)
+	if err != nil {
+		return r, err
+	}
+	r.CreatedLabels = created
+
+	for _, docID := range form.DocIDs {
+		for _, labelID := range labelIDs {
+			docsLabels := DocsLabels{
+				DocID:   docID,
+				LabelID: labelID,
+			}
+
+			q := Q("SELECT COUNT(*) FROM %v WHERE doc_id=? AND label_id=?", DocsLabelsTable)
+			n, err := tx.SelectInt(q, docID, labelID)
+			if err != nil {
+				return r, err
+			}
+			if n > 0 {
+				r.Unchanged = append(r.Unchanged, docsLabels)
+				continue
+			}
+
+			// Labels inserted before in this transaction are seen by the
+			// check, so a request with two labels of an exclusive group fails
+			if err := labels.CheckExclusiveGroup(tx, docID, labelID); err != nil {
+				return r, err
+			}
+
+			if err := tx.Insert(&docsLabels); err != nil {
+				return r, err
+			}
+			r.Attached = append(r.Attached, docsLabels)
+		}
+	}
+
+	return r, nil
+}
+
+func detachLabels(tx gorp.SqlExecutor, form BulkLabelsForm) (BulkLabelsResult, error) {
+	r := newBulkLabelsResult()
+
+	labelIDs, _, err := resolveLabels(tx, form, false)
+	if err != nil {
+		return r, err
+	}
+
+	for _, docID := range form.DocIDs {
+		for _, labelID := range labelIDs {
+			docsLabels := DocsLabels{
+				DocID:   docID,
+				LabelID: labelID,
+			}
+
+			n, err := tx.Delete(&docsLabels)
+			if err != nil {
+				return r, err
+			}
+
+			if n > 0 {
+				r.Detached = append(r.Detached, docsLabels)
+			} else {
+				r.Unchanged = append(r.Unchanged, docsLabels)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// Return the IDs of all labels of the form. Labels given by name which
+// don't exist are created if create is set otherwise they are skipped.
+func resolveLabels(tx gorp.SqlExecutor, form BulkLabelsForm, create bool) ([]int64, []labels.Label, error) {
+	if len(form.LabelIDs) == 0 && len(form.LabelNames) == 0 {
+		return []int64{}, []labels.Label{}, ErrNoLabels
+	}
+
+	ids := []int64{}
+	seen := map[int64]bool{}
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(form.LabelIDs) > 0 {
+		in, params := inParams(form.LabelIDs)
+		q := Q("SELECT COUNT(*) FROM %v WHERE id IN (%v)", labels.LabelsTable, in)
+		n, err := tx.SelectInt(q, params...)
+		if err != nil {
+			return []int64{}, []labels.Label{}, err
+		}
+		if int(n) != len(uniqueIDs(form.LabelIDs)) {
+			return []int64{}, []labels.Label{}, errors.New("unknown label ids")
+		}
+
+		for _, id := range form.LabelIDs {
+			add(id)
+		}
+	}
+
+	created := []labels.Label{}
+	for _, name := range form.LabelNames {
+		if name == "" {
+			return []int64{}, []labels.Label{}, labels.ErrNameMissing
+		}
+
+		label := labels.Label{}
+		q := Q("SELECT * FROM %v WHERE name=?", labels.LabelsTable)
+		l := []labels.Label{}
+		if _, err := tx.Select(&l, q, name); err != nil {
+			return []int64{}, []labels.Label{}, err
+		}
+
+		switch {
+		case len(l) > 0:
+			label = l[0]
+		case create:
+			label.Name = name
+			if err := tx.Insert(&label); err != nil {
+				return []int64{}, []labels.Label{}, err
+			}
+			created = append(created, label)
+		default:
+			continue
+		}
+
+		add(label.ID)
+	}
+
+	return ids, created, nil
+}
+
+func checkDocsExist(tx gorp.SqlExecutor, docIDs []int64) error {
+	ids := uniqueIDs(docIDs)
+	in, params := inParams(ids)
+
+	found := []int64{}
+	q := Q("SELECT id FROM %v WHERE id IN (%v)", DocsTable, in)
+	if _, err := tx.Select(&found, q, params...); err != nil {
+		return err
+	}
+
+	if len(found) == len(ids) {
+		return nil
+	}
+
+	exists := map[int64]bool{}
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	missing := []int64{}
+	for _, id := range ids {
+		if !exists[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	return fmt.Errorf("unknown doc ids %v", missing)
+}
+
+// Lock the doc rows until the end of the transaction. Label changes of a
+// doc are serialized this way, so the exclusive group check and the
+// insert of another transaction can't interleave.
+func lockDocs(tx gorp.SqlExecutor, docIDs []int64) error {
+	in, params := inParams(uniqueIDs(docIDs))
+	q := Q("SELECT id FROM %v WHERE id IN (%v) ORDER BY id FOR UPDATE", DocsTable, in)
+	_, err := tx.Select(&[]int64{}, q, params...)
+	return err
+}
+
+// Attach one label to a doc unless the doc has another label of the
+// same exclusive group
+func JoinLabel(db *gorp.DbMap, docsLabels DocsLabels) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := lockDocs(tx, []int64{docsLabels.DocID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = labels.CheckExclusiveGroup(tx, docsLabels.DocID, docsLabels.LabelID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Insert(&docsLabels); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	r := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			r = append(r, id)
+		}
+	}
+
+	return r
+}
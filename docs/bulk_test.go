@@ -0,0 +1,186 @@
+package docs
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_AttachLabels(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+		&Doc{ID: 2, Name: "b.pdf", Barcode: "2"},
+		&labels.Label{ID: 1, Name: "Beleg"},
+		&DocsLabels{DocID: 1, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := BulkLabelsForm{
+		DocIDs:     []int64{1, 2},
+		LabelIDs:   []int64{1},
+		LabelNames: []string{"Beleg", "Hosting"},
+	}
+
+	r, err := AttachLabels(db, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := BulkLabelsResult{
+		Attached: []DocsLabels{
+			{DocID: 1, LabelID: 2},
+			{DocID: 2, LabelID: 1},
+			{DocID: 2, LabelID: 2},
+		},
+		Detached: []DocsLabels{},
+		Unchanged: []DocsLabels{
+			{DocID: 1, LabelID: 1},
+		},
+		CreatedLabels: []labels.Label{
+			{ID: 2, Name: "Hosting"},
+		},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_AttachLabels_UnknownDoc(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := BulkLabelsForm{
+		DocIDs:     []int64{1, 3},
+		LabelNames: []string{"Hosting"},
+	}
+
+	if _, err := AttachLabels(db, form); err == nil {
+		t.Fatal("Expect error was nil")
+	}
+
+	// The transaction is rolled back so no label is created
+	n, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v", labels.LabelsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("Expect %v was %v", 0, n)
+	}
+}
+
+func Test_AttachLabels_ExclusiveGroup(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+		&labels.LabelGroup{ID: 1, Name: "Type", Exclusive: true},
+		&labels.Label{ID: 1, Name: "Invoice", GroupID: 1},
+		&labels.Label{ID: 2, Name: "Contract", GroupID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := BulkLabelsForm{
+		DocIDs:   []int64{1},
+		LabelIDs: []int64{1, 2},
+	}
+
+	_, err = AttachLabels(db, form)
+	if _, ok := err.(labels.GroupConflictError); !ok {
+		t.Fatalf("Expect GroupConflictError was %v", err)
+	}
+
+	n, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v", DocsLabelsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("Expect %v was %v", 0, n)
+	}
+}
+
+func Test_DetachLabels(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+		&Doc{ID: 2, Name: "b.pdf", Barcode: "2"},
+		&labels.Label{ID: 1, Name: "Beleg"},
+		&DocsLabels{DocID: 1, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := BulkLabelsForm{
+		DocIDs:     []int64{1, 2},
+		LabelNames: []string{"Beleg", "Unknown"},
+	}
+
+	r, err := DetachLabels(db, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := BulkLabelsResult{
+		Attached: []DocsLabels{},
+		Detached: []DocsLabels{
+			{DocID: 1, LabelID: 1},
+		},
+		Unchanged: []DocsLabels{
+			{DocID: 2, LabelID: 1},
+		},
+		CreatedLabels: []labels.Label{},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_JoinLabel_Parallel(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+		&labels.LabelGroup{ID: 1, Name: "Type", Exclusive: true},
+		&labels.Label{ID: 1, Name: "Invoice", GroupID: 1},
+		&labels.Label{ID: 2, Name: "Contract", GroupID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for _, labelID := range []int64{1, 2, 1, 2, 1, 2} {
+		wg.Add(1)
+		go func(labelID int64) {
+			defer wg.Done()
+			JoinLabel(db, DocsLabels{DocID: 1, LabelID: labelID})
+		}(labelID)
+	}
+	wg.Wait()
+
+	n, err := db.SelectInt("SELECT COUNT(*) FROM docs_labels WHERE doc_id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Expect %v was %v", 1, n)
+	}
+}
@@ -1,6 +1,9 @@
 package docs
 
 import (
+	"log"
+
+	"github.com/tochti/docMa-handler/common"
 	"github.com/tochti/docMa-handler/labels"
 	"gopkg.in/gorp.v1"
 )
@@ -10,21 +13,60 @@ func AddTables(db *gorp.DbMap) {
 		SetKeys(true, "id")
 	tMap.ColMap("name").SetUnique(true).SetNotNull(true)
 	tMap.ColMap("barcode").SetUnique(true)
+	tMap.SetVersionCol("version")
 
 	db.AddTableWithName(DocAccountData{}, DocAccountDataTable).
-		SetKeys(false, "doc_id")
+		SetKeys(false, "doc_id").
+		SetVersionCol("version")
 
 	db.AddTableWithName(DocNumber{}, DocNumbersTable).
 		SetKeys(false, "doc_id", "number")
 
 	db.AddTableWithName(DocsLabels{}, DocsLabelsTable).
 		SetKeys(false, "doc_id", "label_id")
+
+	tMap = db.AddTableWithName(DocFile{}, DocFilesTable).
+		SetKeys(true, "id")
+	tMap.ColMap("name").SetUnique(true).SetNotNull(true)
+
+	db.AddTableWithName(DocRevision{}, DocRevisionsTable).
+		SetKeys(true, "id")
+}
+
+// Called with every created doc, e.g. to apply labelling rules
+type CreateHook func(db *gorp.DbMap, doc Doc) error
+
+var createHooks = []CreateHook{}
+
+// Register a function which is called after a doc was created
+func AddCreateHook(h CreateHook) {
+	createHooks = append(createHooks, h)
+}
+
+// Insert the doc and run all create hooks. A failing hook doesn't undo the
+// creation of the doc, the error is only logged.
+func CreateDoc(db *gorp.DbMap, doc *Doc) error {
+	if err := db.Insert(doc); err != nil {
+		return err
+	}
+
+	runCreateHooks(db, *doc)
+
+	return nil
+}
+
+func runCreateHooks(db *gorp.DbMap, doc Doc) {
+	for _, h := range createHooks {
+		if err := h(db, doc); err != nil {
+			log.Printf("create hook failed for doc %v: %v", doc.ID, err)
+		}
+	}
 }
 
 func FindLabelsOfDoc(db *gorp.DbMap, docID int64) ([]labels.Label, error) {
 
 	q := Q(`
-	SELECT labels.id,labels.name 
+	SELECT labels.*
 	FROM %v as labels, %v as labels_docs
 	WHERE labels_docs.doc_id=?
 	AND labels.id=labels_docs.label_id`,
@@ -66,30 +108,93 @@ func ReadAccountData(db *gorp.DbMap, docID int64) (DocAccountData, error) {
 	return accountData, nil
 }
 
-func FindDocsWithLabel(db *gorp.DbMap, labelID int64) ([]Doc, error) {
+// Find docs with the label, if withSubLabels is set docs with a descendant
+// of the label are found too
+func FindDocsWithLabel(db *gorp.DbMap, labelID int64, withSubLabels bool, page common.Page) ([]Doc, int64, error) {
+	order, err := page.SQL(DocSortFields, "docs.id")
+	if err != nil {
+		return []Doc{}, 0, err
+	}
+
+	labelIDs := []int64{labelID}
+	if withSubLabels {
+		labelIDs, err = labels.DescendantIDs(db, labelID)
+		if err != nil {
+			return []Doc{}, 0, err
+		}
+	}
+	in, params := inParams(labelIDs)
+
 	d := []Doc{}
 
 	q := Q(`
-	SELECT 
+	SELECT DISTINCT
 		docs.id,
 		docs.name,
 		docs.barcode,
 		docs.date_of_scan,
 		docs.date_of_receipt,
-		docs.note
+		docs.note,
+		docs.placeholder,
+		docs.version
 	FROM %v as docs, %v as docs_labels
-	WHERE docs_labels.label_id=?
-	AND docs.id=docs_labels.doc_id`, DocsTable, DocsLabelsTable)
-	_, err := db.Select(&d, q, labelID)
+	WHERE docs_labels.label_id IN (%v)
+	AND docs.id=docs_labels.doc_id
+	%v`, DocsTable, DocsLabelsTable, in, order)
+	_, err = db.Select(&d, q, params...)
 	if err != nil {
-		return []Doc{}, err
+		return []Doc{}, 0, err
+	}
+
+	q = Q(`
+	SELECT COUNT(DISTINCT docs.id)
+	FROM %v as docs, %v as docs_labels
+	WHERE docs_labels.label_id IN (%v)
+	AND docs.id=docs_labels.doc_id`, DocsTable, DocsLabelsTable, in)
+	total, err := db.SelectInt(q, params...)
+	if err != nil {
+		return []Doc{}, 0, err
+	}
+
+	return d, total, nil
+}
+
+// Remove the doc with its label connections, account data, doc numbers, doc
+// files and revisions. The files on disk are kept.
+func RemoveDoc(db gorp.SqlExecutor, docID int64) error {
+	if err := removeDoc(db, docID); err != nil {
+		return err
 	}
 
-	return d, nil
+	_, err := db.Exec(Q("DELETE FROM %v WHERE doc_id=?", DocRevisionsTable), docID)
+	return err
+}
+
+// Remove the doc like RemoveDoc but keep its revisions
+func removeDoc(db gorp.SqlExecutor, docID int64) error {
+	// Not db.Delete, it would only delete the doc of version 0
+	if _, err := db.Exec(Q("DELETE FROM %v WHERE id=?", DocsTable), docID); err != nil {
+		return err
+	}
+
+	if err := RemoveDocLabelConnection(db, docID); err != nil {
+		return err
+	}
+
+	if err := RemoveAccountData(db, docID); err != nil {
+		return err
+	}
+
+	if err := RemoveDocNumbers(db, docID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(Q("DELETE FROM %v WHERE doc_id=?", DocFilesTable), docID)
+	return err
 }
 
 // Remove all doc labels for one doc
-func RemoveDocLabelConnection(db *gorp.DbMap, docID int64) error {
+func RemoveDocLabelConnection(db gorp.SqlExecutor, docID int64) error {
 	q := Q("DELETE FROM %v WHERE doc_id=?", DocsLabelsTable)
 
 	_, err := db.Exec(q, docID)
@@ -101,7 +206,7 @@ func RemoveDocLabelConnection(db *gorp.DbMap, docID int64) error {
 }
 
 // Remove doc account data for one doc
-func RemoveAccountData(db *gorp.DbMap, docID int64) error {
+func RemoveAccountData(db gorp.SqlExecutor, docID int64) error {
 	q := Q("DELETE FROM %v WHERE doc_id=?", DocAccountDataTable)
 
 	_, err := db.Exec(q, docID)
@@ -113,7 +218,7 @@ func RemoveAccountData(db *gorp.DbMap, docID int64) error {
 }
 
 // Remove all doc numbers for one doc
-func RemoveDocNumbers(db *gorp.DbMap, docID int64) error {
+func RemoveDocNumbers(db gorp.SqlExecutor, docID int64) error {
 	q := Q("DELETE FROM %v WHERE doc_id=?", DocNumbersTable)
 
 	_, err := db.Exec(q, docID)
@@ -123,3 +228,49 @@ func RemoveDocNumbers(db *gorp.DbMap, docID int64) error {
 
 	return nil
 }
+
+// Find label connections whose doc or label doesn't exist anymore
+func FindDanglingDocsLabels(db gorp.SqlExecutor) ([]DocsLabels, error) {
+	q := Q(`
+	SELECT docs_labels.doc_id, docs_labels.label_id
+	FROM %v as docs_labels
+	LEFT JOIN %v as labels ON labels.id = docs_labels.label_id
+	LEFT JOIN %v as docs ON docs.id = docs_labels.doc_id
+	WHERE labels.id IS NULL OR docs.id IS NULL
+	ORDER BY docs_labels.doc_id, docs_labels.label_id`,
+		DocsLabelsTable, labels.LabelsTable, DocsTable)
+
+	l := []DocsLabels{}
+	if _, err := db.Select(&l, q); err != nil {
+		return []DocsLabels{}, err
+	}
+
+	return l, nil
+}
+
+// Remove all dangling label connections and return them
+func RemoveDanglingDocsLabels(db *gorp.DbMap) ([]DocsLabels, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return []DocsLabels{}, err
+	}
+
+	l, err := FindDanglingDocsLabels(tx)
+	if err != nil {
+		tx.Rollback()
+		return []DocsLabels{}, err
+	}
+
+	for i := range l {
+		if _, err := tx.Delete(&l[i]); err != nil {
+			tx.Rollback()
+			return []DocsLabels{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return []DocsLabels{}, err
+	}
+
+	return l, nil
+}
@@ -134,7 +134,7 @@ func Test_FindDocsWithLabel(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r, err := FindDocsWithLabel(db, 1)
+	r, total, err := FindDocsWithLabel(db, 1, false, common.Page{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -144,4 +144,92 @@ func Test_FindDocsWithLabel(t *testing.T) {
 		t.Fatalf("Expect %v was %v", expect, r)
 	}
 
+	if total != 1 {
+		t.Fatalf("Expect %v was %v", 1, total)
+	}
+
+}
+
+func Test_FindDocsWithLabel_Page(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	docs := []Doc{
+		{ID: 1, Name: "a.pdf", Barcode: "1"},
+		{ID: 2, Name: "c.pdf", Barcode: "2"},
+		{ID: 3, Name: "b.pdf", Barcode: "3"},
+	}
+
+	if err := db.Insert(&labels.Label{ID: 1, Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range docs {
+		docsLabels := DocsLabels{
+			DocID:   docs[i].ID,
+			LabelID: 1,
+		}
+		if err := db.Insert(&docs[i], &docsLabels); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page := common.Page{
+		Limit:  2,
+		Offset: 1,
+		Sort:   "name",
+		Order:  "desc",
+	}
+
+	r, total, err := FindDocsWithLabel(db, 1, false, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Doc{docs[2], docs[0]}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	if total != 3 {
+		t.Fatalf("Expect %v was %v", 3, total)
+	}
+}
+
+func Test_RemoveDanglingDocsLabels(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1"},
+		&labels.Label{ID: 1, Name: "Miete"},
+		&DocsLabels{DocID: 1, LabelID: 1},
+		// label is missing
+		&DocsLabels{DocID: 1, LabelID: 2},
+		// doc is missing
+		&DocsLabels{DocID: 2, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := RemoveDanglingDocsLabels(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []DocsLabels{
+		{DocID: 1, LabelID: 2},
+		{DocID: 2, LabelID: 1},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	r, err = FindDanglingDocsLabels(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 0 {
+		t.Fatalf("Expect no dangling labels was %v", r)
+	}
 }
@@ -0,0 +1,166 @@
+package docs
+
+import (
+	"strings"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+type (
+	// Doc with all related data embedded
+	ExpandedDoc struct {
+		Doc
+		Labels         []labels.Label                  `json:"labels"`
+		DocNumbers     []DocNumber                     `json:"doc_numbers"`
+		AccountData    *DocAccountData                 `json:"account_data"`
+		AccountingData []accountingData.AccountingData `json:"accounting_data"`
+	}
+
+	docLabel struct {
+		DocID int64 `db:"doc_id"`
+		labels.Label
+	}
+)
+
+// Load labels, doc numbers, account data and accounting data of all docs.
+// Every kind of data is read with one query for all docs.
+func ExpandDocs(db *gorp.DbMap, docs []Doc) ([]ExpandedDoc, error) {
+	r := make([]ExpandedDoc, len(docs))
+	if len(docs) == 0 {
+		return r, nil
+	}
+
+	index := map[int64]*ExpandedDoc{}
+	ids := []int64{}
+	for i, d := range docs {
+		r[i] = ExpandedDoc{
+			Doc:            d,
+			Labels:         []labels.Label{},
+			DocNumbers:     []DocNumber{},
+			AccountingData: []accountingData.AccountingData{},
+		}
+		index[d.ID] = &r[i]
+		ids = append(ids, d.ID)
+	}
+	in, params := inParams(ids)
+
+	// Labels
+	docLabels := []docLabel{}
+	q := Q(`
+	SELECT docs_labels.doc_id, labels.*
+	FROM %v as labels, %v as docs_labels
+	WHERE docs_labels.doc_id IN (%v)
+	AND labels.id=docs_labels.label_id
+	ORDER BY labels.name`, labels.LabelsTable, DocsLabelsTable, in)
+	if _, err := db.Select(&docLabels, q, params...); err != nil {
+		return []ExpandedDoc{}, err
+	}
+	for _, l := range docLabels {
+		d := index[l.DocID]
+		d.Labels = append(d.Labels, l.Label)
+	}
+
+	// Doc numbers
+	docNumbers := []DocNumber{}
+	q = Q("SELECT * FROM %v WHERE doc_id IN (%v) ORDER BY number",
+		DocNumbersTable, in)
+	if _, err := db.Select(&docNumbers, q, params...); err != nil {
+		return []ExpandedDoc{}, err
+	}
+	numbers := []string{}
+	for _, n := range docNumbers {
+		d := index[n.DocID]
+		d.DocNumbers = append(d.DocNumbers, n)
+
+		// Invalid numbers can't match any booking
+		if _, err := accountingData.ParseDocNumber(n.Number); err == nil {
+			numbers = append(numbers, n.Number)
+		}
+	}
+
+	// Account data
+	accountData := []DocAccountData{}
+	q = Q("SELECT * FROM %v WHERE doc_id IN (%v)", DocAccountDataTable, in)
+	if _, err := db.Select(&accountData, q, params...); err != nil {
+		return []ExpandedDoc{}, err
+	}
+	periods := []accountingData.AccountPeriod{}
+	for i, a := range accountData {
+		index[a.DocID].AccountData = &accountData[i]
+		periods = append(periods, accountPeriod(a))
+	}
+
+	// Accounting data linked by doc numbers
+	byNumbers := []accountingData.AccountingData{}
+	if len(numbers) > 0 {
+		var err error
+		byNumbers, err = accountingData.FindAccountingDataByDocNumbers(db, numbers)
+		if err != nil {
+			return []ExpandedDoc{}, err
+		}
+	}
+
+	// Accounting data linked by account data
+	byAccounts, err := accountingData.FindAccountingDataByAccountPeriods(db, periods)
+	if err != nil {
+		return []ExpandedDoc{}, err
+	}
+
+	for i := range r {
+		d := &r[i]
+
+		r1 := []accountingData.AccountingData{}
+		for _, n := range d.DocNumbers {
+			for _, a := range byNumbers {
+				if matchDocNumber(n.Number, a) {
+					r1 = append(r1, a)
+				}
+			}
+		}
+
+		r2 := []accountingData.AccountingData{}
+		if d.AccountData != nil {
+			p := accountPeriod(*d.AccountData)
+			for _, a := range byAccounts {
+				if p.Match(a) {
+					r2 = append(r2, a)
+				}
+			}
+		}
+
+		d.AccountingData = mergeAccountingData(r1, r2)
+	}
+
+	return r, nil
+}
+
+func accountPeriod(a DocAccountData) accountingData.AccountPeriod {
+	return accountingData.AccountPeriod{
+		AccountNumber: a.AccountNumber,
+		From:          a.PeriodFrom,
+		To:            a.PeriodTo,
+	}
+}
+
+func matchDocNumber(number string, a accountingData.AccountingData) bool {
+	p, err := accountingData.ParseDocNumber(number)
+	if err != nil {
+		return false
+	}
+
+	return p.Match(a)
+}
+
+// Create the placeholders and parameters for a IN (...) filter
+func inParams(ids []int64) (string, []interface{}) {
+	p := make([]string, len(ids))
+	params := make([]interface{}, len(ids))
+	for i, id := range ids {
+		p[i] = "?"
+		params[i] = id
+	}
+
+	return strings.Join(p, ","), params
+}
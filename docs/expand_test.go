@@ -0,0 +1,87 @@
+package docs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_ExpandDocs(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables, accountingData.AddTables)
+
+	d := time.Date(2016, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	doc1 := Doc{ID: 1, Name: "a.pdf", Barcode: "1"}
+	doc2 := Doc{ID: 2, Name: "b.pdf", Barcode: "2"}
+	doc3 := Doc{ID: 3, Name: "c.pdf", Barcode: "3"}
+	label := labels.Label{ID: 1, Name: "Miete"}
+	docNumber := DocNumber{DocID: 1, Number: "B6"}
+	accountData := DocAccountData{
+		DocID:         2,
+		PeriodFrom:    d.AddDate(0, 0, -1),
+		PeriodTo:      d.AddDate(0, 0, 1),
+		AccountNumber: 1400,
+	}
+	booking1 := accountingData.AccountingData{
+		ID:             1,
+		DocNumberRange: "B",
+		DocNumber:      "6",
+	}
+	booking2 := accountingData.AccountingData{
+		ID:            2,
+		DocDate:       d,
+		DebitAccount:  1400,
+		CreditAccount: 1500,
+	}
+
+	err := db.Insert(
+		&doc1, &doc2, &doc3,
+		&label,
+		&DocsLabels{DocID: 1, LabelID: 1},
+		&DocsLabels{DocID: 2, LabelID: 1},
+		&docNumber,
+		&accountData,
+		&booking1,
+		&booking2,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ExpandDocs(db, []Doc{doc1, doc2, doc3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 3 {
+		t.Fatalf("Expect %v was %v", 3, len(r))
+	}
+
+	if !reflect.DeepEqual(r[0].Labels, []labels.Label{label}) ||
+		!reflect.DeepEqual(r[0].DocNumbers, []DocNumber{docNumber}) ||
+		r[0].AccountData != nil ||
+		len(r[0].AccountingData) != 1 ||
+		r[0].AccountingData[0].ID != booking1.ID {
+		t.Fatalf("Unexpected expanded doc %v", r[0])
+	}
+
+	if !reflect.DeepEqual(r[1].Labels, []labels.Label{label}) ||
+		len(r[1].DocNumbers) != 0 ||
+		r[1].AccountData == nil ||
+		r[1].AccountData.AccountNumber != 1400 ||
+		len(r[1].AccountingData) != 1 ||
+		r[1].AccountingData[0].ID != booking2.ID {
+		t.Fatalf("Unexpected expanded doc %v", r[1])
+	}
+
+	if len(r[2].Labels) != 0 ||
+		len(r[2].DocNumbers) != 0 ||
+		r[2].AccountData != nil ||
+		len(r[2].AccountingData) != 0 {
+		t.Fatalf("Unexpected expanded doc %v", r[2])
+	}
+}
@@ -0,0 +1,143 @@
+package docs
+
+import (
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+type (
+	LabelFacet struct {
+		LabelID int64  `db:"label_id" json:"label_id"`
+		Name    string `db:"name" json:"name"`
+		Count   int64  `db:"count" json:"count"`
+	}
+
+	// Month is formatted as YYYY-MM
+	MonthFacet struct {
+		Month string `db:"month" json:"month"`
+		Count int64  `db:"count" json:"count"`
+	}
+
+	AccountNumberFacet struct {
+		AccountNumber int   `db:"account_number" json:"account_number"`
+		Count         int64 `db:"count" json:"count"`
+	}
+
+	// Number of docs with and without linked accounting data
+	AccountingDataFacet struct {
+		Linked   int64 `json:"linked"`
+		Unlinked int64 `json:"unlinked"`
+	}
+
+	Facets struct {
+		Labels         []LabelFacet         `json:"labels"`
+		MonthsOfScan   []MonthFacet         `json:"months_of_scan"`
+		AccountNumbers []AccountNumberFacet `json:"account_numbers"`
+		AccountingData AccountingDataFacet  `json:"accounting_data"`
+	}
+
+	// Docs is either []Doc or []ExpandedDoc
+	SearchResult struct {
+		Docs   interface{} `json:"docs"`
+		Total  int64       `json:"total"`
+		Facets Facets      `json:"facets"`
+	}
+)
+
+// Count how the complete result of a search breaks down by labels, month of
+// scan, account number and linked accounting data
+func SearchFacets(db *gorp.DbMap, searchForm SearchForm) (Facets, error) {
+	facets := Facets{
+		Labels:         []LabelFacet{},
+		MonthsOfScan:   []MonthFacet{},
+		AccountNumbers: []AccountNumberFacet{},
+	}
+
+	if searchForm.IsEmpty() {
+		return facets, nil
+	}
+
+	searchForm, err := resolveSubLabels(db, searchForm)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	q := buildSearchQuery(searchForm)
+	docIDs := Q(`SELECT docs.id FROM %v WHERE %v`, q.from, q.where)
+
+	sel := Q(`
+	SELECT l.id AS label_id, l.name AS name, COUNT(*) AS count
+	FROM %v as l, %v as dl
+	WHERE dl.label_id = l.id
+	AND dl.doc_id IN (%v)
+	GROUP BY l.id, l.name
+	ORDER BY count DESC, l.name`,
+		labels.LabelsTable, DocsLabelsTable, docIDs)
+	if _, err := db.Select(&facets.Labels, sel, q.params...); err != nil {
+		return Facets{}, err
+	}
+
+	sel = Q(`
+	SELECT DATE_FORMAT(d.date_of_scan, '%%Y-%%m') AS month, COUNT(*) AS count
+	FROM %v as d
+	WHERE d.id IN (%v)
+	GROUP BY month
+	ORDER BY month`, DocsTable, docIDs)
+	if _, err := db.Select(&facets.MonthsOfScan, sel, q.params...); err != nil {
+		return Facets{}, err
+	}
+
+	sel = Q(`
+	SELECT ad.account_number AS account_number, COUNT(*) AS count
+	FROM %v as ad
+	WHERE ad.doc_id IN (%v)
+	GROUP BY ad.account_number
+	ORDER BY ad.account_number`, DocAccountDataTable, docIDs)
+	if _, err := db.Select(&facets.AccountNumbers, sel, q.params...); err != nil {
+		return Facets{}, err
+	}
+
+	total, err := db.SelectInt(
+		Q(`SELECT COUNT(*) FROM %v as d WHERE d.id IN (%v)`, DocsTable, docIDs),
+		q.params...,
+	)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	// A doc is linked if one of its doc numbers or its account data
+	// matches a booking, like in FindAllAccountingDataOfDocHandler
+	sel = Q(`
+	SELECT COUNT(*)
+	FROM %v as d
+	WHERE d.id IN (%v)
+	AND (
+		EXISTS (
+			SELECT 1 FROM %v as dn, %v as acc
+			WHERE dn.doc_id = d.id
+			AND CONCAT(acc.doc_number_range, acc.doc_number) = dn.number
+		)
+		OR EXISTS (
+			SELECT 1 FROM %v as ad, %v as acc
+			WHERE ad.doc_id = d.id
+			AND (acc.credit_account = ad.account_number OR acc.debit_account = ad.account_number)
+			AND acc.doc_date BETWEEN ad.period_from AND ad.period_to
+		)
+	)`,
+		DocsTable, docIDs,
+		DocNumbersTable, accountingData.AccountingDataTable,
+		DocAccountDataTable, accountingData.AccountingDataTable,
+	)
+	linked, err := db.SelectInt(sel, q.params...)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	facets.AccountingData = AccountingDataFacet{
+		Linked:   linked,
+		Unlinked: total - linked,
+	}
+
+	return facets, nil
+}
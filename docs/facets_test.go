@@ -0,0 +1,73 @@
+package docs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_SearchFacets(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables, accountingData.AddTables)
+
+	jan := time.Date(2016, time.January, 10, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2016, time.February, 10, 0, 0, 0, 0, time.UTC)
+
+	err := db.Insert(
+		&Doc{ID: 1, Name: "a.pdf", Barcode: "1", Note: "Miete", DateOfScan: jan},
+		&Doc{ID: 2, Name: "b.pdf", Barcode: "2", Note: "Miete", DateOfScan: feb},
+		&Doc{ID: 3, Name: "c.pdf", Barcode: "3", Note: "Miete", DateOfScan: feb},
+		&Doc{ID: 4, Name: "d.pdf", Barcode: "4", Note: "Strom", DateOfScan: feb},
+		&labels.Label{ID: 1, Name: "Wohnung"},
+		&labels.Label{ID: 2, Name: "Bank"},
+		&DocsLabels{DocID: 1, LabelID: 1},
+		&DocsLabels{DocID: 2, LabelID: 1},
+		&DocsLabels{DocID: 2, LabelID: 2},
+		&DocsLabels{DocID: 4, LabelID: 2},
+		&DocAccountData{
+			DocID:         1,
+			PeriodFrom:    jan.AddDate(0, 0, -10),
+			PeriodTo:      jan.AddDate(0, 0, 10),
+			AccountNumber: 1400,
+		},
+		&DocNumber{DocID: 3, Number: "B6"},
+		&accountingData.AccountingData{
+			DocNumberRange: "B",
+			DocNumber:      "6",
+			DocDate:        feb,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := SearchFacets(db, SearchForm{Note: TextFilter{Value: "Miete"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := Facets{
+		Labels: []LabelFacet{
+			{LabelID: 1, Name: "Wohnung", Count: 2},
+			{LabelID: 2, Name: "Bank", Count: 1},
+		},
+		MonthsOfScan: []MonthFacet{
+			{Month: "2016-01", Count: 1},
+			{Month: "2016-02", Count: 2},
+		},
+		AccountNumbers: []AccountNumberFacet{
+			{AccountNumber: 1400, Count: 1},
+		},
+		AccountingData: AccountingDataFacet{
+			Linked:   1,
+			Unlinked: 2,
+		},
+	}
+
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
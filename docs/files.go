@@ -0,0 +1,425 @@
+package docs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/tochti/docMa-handler/pdfTools"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrNoDocFiles          = errors.New("no doc files to merge")
+	ErrAccountDataConflict = errors.New("only one of the merged docs can have account data")
+)
+
+type (
+	DocFileForm struct {
+		Role string
+		// Name of the file, made unique if it is taken
+		Name string
+		File []byte
+		// Position in the files of the doc, appended if it is 0
+		Position int
+	}
+
+	DocFileUpdate struct {
+		Role     string `json:"role"`
+		Position int    `json:"position" valid:"min=1"`
+	}
+
+	// Merge the files into the primary file of the doc, all files if
+	// FileIDs is empty
+	MergeDocFilesForm struct {
+		FileIDs []int64 `json:"file_ids"`
+	}
+
+	// Append the files of the docs to the primary file of the target doc
+	MergeDocsForm struct {
+		DocIDs []int64 `json:"doc_ids" valid:"required,min=1"`
+	}
+)
+
+// Store a file in the files directory and attach it to the doc
+func AddDocFile(db *gorp.DbMap, files string, docID int64, form DocFileForm) (DocFile, error) {
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), docID)
+	if err != nil {
+		return DocFile{}, err
+	}
+
+	name := path.Base(form.Name)
+	if form.Name == "" || name == "." || name == "/" {
+		name = "file.pdf"
+	}
+	name, err = FreeName(db, files, name)
+	if err != nil {
+		return DocFile{}, err
+	}
+
+	filename := path.Join(files, name)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return DocFile{}, ErrNameTaken
+	}
+	if err != nil {
+		return DocFile{}, err
+	}
+	_, err = f.Write(form.File)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(filename)
+		return DocFile{}, err
+	}
+
+	position := form.Position
+	if position <= 0 {
+		q := Q("SELECT COALESCE(MAX(position), 0) FROM %v WHERE doc_id=?", DocFilesTable)
+		max, err := db.SelectInt(q, docID)
+		if err != nil {
+			os.Remove(filename)
+			return DocFile{}, err
+		}
+		position = int(max) + 1
+	}
+
+	docFile := DocFile{
+		DocID:    docID,
+		Name:     name,
+		Role:     form.Role,
+		Position: position,
+	}
+	if err := db.Insert(&docFile); err != nil {
+		os.Remove(filename)
+		return DocFile{}, err
+	}
+
+	return docFile, nil
+}
+
+// Files of the doc ordered by position
+func ReadDocFiles(db gorp.SqlExecutor, docID int64) ([]DocFile, error) {
+	l := []DocFile{}
+	q := Q("SELECT * FROM %v WHERE doc_id=? ORDER BY position, id", DocFilesTable)
+	if _, err := db.Select(&l, q, docID); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func ReadDocFile(db gorp.SqlExecutor, docID, fileID int64) (DocFile, error) {
+	f := DocFile{}
+	q := Q("SELECT * FROM %v WHERE doc_id=? AND id=?", DocFilesTable)
+	if err := db.SelectOne(&f, q, docID, fileID); err != nil {
+		return DocFile{}, err
+	}
+
+	return f, nil
+}
+
+// Change the role and the position of a file
+func UpdateDocFile(db gorp.SqlExecutor, docID, fileID int64, u DocFileUpdate) (DocFile, error) {
+	f, err := ReadDocFile(db, docID, fileID)
+	if err != nil {
+		return DocFile{}, err
+	}
+
+	f.Role = u.Role
+	f.Position = u.Position
+	if _, err := db.Update(&f); err != nil {
+		return DocFile{}, err
+	}
+
+	return f, nil
+}
+
+// Remove the file from the doc and from the files directory
+func RemoveDocFile(db gorp.SqlExecutor, files string, docID, fileID int64) error {
+	f, err := ReadDocFile(db, docID, fileID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Delete(&f); err != nil {
+		return err
+	}
+
+	err = os.Remove(path.Join(files, f.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Append the files to the primary file of the doc in the order of their
+// positions, so ReadDocFileHandler serves the complete document. The old
+// primary file is kept for the revisions and the merged files stay with
+// the doc, so a rollback to the revision before the merge restores the doc
+// as it was. Remove them with RemoveDocFile if they aren't needed anymore.
+func MergeDocFiles(db *gorp.DbMap, files string, docID int64, form MergeDocFilesForm, author string) (Doc, error) {
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), docID)
+	if err != nil {
+		return Doc{}, err
+	}
+	if doc.Placeholder {
+		return Doc{}, ErrNoFile
+	}
+
+	all, err := ReadDocFiles(db, docID)
+	if err != nil {
+		return Doc{}, err
+	}
+
+	merge := []DocFile{}
+	for _, f := range all {
+		if len(form.FileIDs) == 0 || containsID(form.FileIDs, f.ID) {
+			merge = append(merge, f)
+		}
+	}
+	if len(merge) == 0 || len(merge) < len(uniqueIDs(form.FileIDs)) {
+		return Doc{}, ErrNoDocFiles
+	}
+
+	primary := path.Join(files, doc.Name)
+	l := []string{primary}
+	for _, f := range merge {
+		l = append(l, path.Join(files, f.Name))
+	}
+
+	tmp, err := ioutil.TempFile(files, ".merge")
+	if err != nil {
+		return Doc{}, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := pdfTools.Merge(l, tmp.Name()); err != nil {
+		return Doc{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Doc{}, err
+	}
+
+	doc, err = lockDoc(tx, docID)
+	if err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	if err := initialRevision(tx, doc); err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+	archive, err := archiveFile(tx, files, doc)
+	if err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+	rollback := func() {
+		tx.Rollback()
+		os.Remove(archive)
+	}
+
+	if _, err := createRevision(tx, doc, author, RevisionMerge); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	if err := bumpVersion(tx, &doc); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+
+	if err := commitFile(tx, tmp.Name(), primary, archive); err != nil {
+		return Doc{}, err
+	}
+
+	return doc, nil
+}
+
+// Append the primary files of the docs to the target doc. The labels, doc
+// numbers, doc files and account data of the docs are moved to the
+// target, the docs and their primary files are removed afterwards. The
+// revisions of the removed docs are kept with their files archived, the
+// old file of the target too.
+func MergeDocs(db *gorp.DbMap, files string, docID int64, form MergeDocsForm, author string) (Doc, error) {
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), docID)
+	if err != nil {
+		return Doc{}, err
+	}
+	if doc.Placeholder {
+		return Doc{}, ErrNoFile
+	}
+
+	primary := path.Join(files, doc.Name)
+	l := []string{primary}
+	others := []Doc{}
+	for _, id := range uniqueIDs(form.DocIDs) {
+		if id == docID {
+			continue
+		}
+
+		d := Doc{}
+		err := db.SelectOne(&d, Q("SELECT * FROM %v WHERE id=?", DocsTable), id)
+		if err != nil {
+			return Doc{}, err
+		}
+		if d.Placeholder {
+			return Doc{}, ErrNoFile
+		}
+
+		others = append(others, d)
+		l = append(l, path.Join(files, d.Name))
+	}
+	if len(others) == 0 {
+		return Doc{}, ErrNoDocFiles
+	}
+
+	tmp, err := ioutil.TempFile(files, ".merge")
+	if err != nil {
+		return Doc{}, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := pdfTools.Merge(l, tmp.Name()); err != nil {
+		return Doc{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Doc{}, err
+	}
+
+	archives := []string{}
+	rollback := func() {
+		tx.Rollback()
+		for _, a := range archives {
+			os.Remove(a)
+		}
+	}
+
+	ids := []int64{docID}
+	for _, d := range others {
+		ids = append(ids, d.ID)
+	}
+	if err := lockDocs(tx, ids); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+
+	doc, err = lockDoc(tx, docID)
+	if err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	if err := initialRevision(tx, doc); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	archive, err := archiveFile(tx, files, doc)
+	if err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	archives = append(archives, archive)
+
+	for _, d := range others {
+		if err := moveDocData(tx, d.ID, docID); err != nil {
+			rollback()
+			return Doc{}, err
+		}
+
+		if err := initialRevision(tx, d); err != nil {
+			rollback()
+			return Doc{}, err
+		}
+		archive, err := archiveFile(tx, files, d)
+		if err != nil {
+			rollback()
+			return Doc{}, err
+		}
+		archives = append(archives, archive)
+
+		if err := removeDoc(tx, d.ID); err != nil {
+			rollback()
+			return Doc{}, err
+		}
+	}
+
+	if _, err := createRevision(tx, doc, author, RevisionMerge); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	if err := bumpVersion(tx, &doc); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+
+	if err := commitFile(tx, tmp.Name(), primary, archive); err != nil {
+		for _, a := range archives[1:] {
+			os.Remove(a)
+		}
+		return Doc{}, err
+	}
+
+	for _, d := range others {
+		os.Remove(path.Join(files, d.Name))
+	}
+
+	return doc, nil
+}
+
+// Move labels, doc numbers, doc files and account data from one doc to
+// another. Labels and doc numbers the target already has are skipped, a
+// target with account data can't get another one.
+func moveDocData(tx gorp.SqlExecutor, from, to int64) error {
+	for _, table := range []string{DocsLabelsTable, DocNumbersTable} {
+		q := Q("UPDATE IGNORE %v SET doc_id=? WHERE doc_id=?", table)
+		if _, err := tx.Exec(q, to, from); err != nil {
+			return err
+		}
+	}
+
+	q := Q("SELECT COUNT(*) FROM %v WHERE doc_id IN (?, ?)", DocAccountDataTable)
+	n, err := tx.SelectInt(q, from, to)
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		return ErrAccountDataConflict
+	}
+
+	q = Q("UPDATE %v SET doc_id=? WHERE doc_id=?", DocAccountDataTable)
+	if _, err := tx.Exec(q, to, from); err != nil {
+		return err
+	}
+
+	q = Q(`
+	UPDATE %v
+	SET doc_id=?, position=position+(
+		SELECT p FROM (SELECT COALESCE(MAX(position), 0) AS p FROM %v WHERE doc_id=?) AS m
+	)
+	WHERE doc_id=?`, DocFilesTable, DocFilesTable)
+	_, err = tx.Exec(q, to, to, from)
+	return err
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
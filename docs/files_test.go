@@ -0,0 +1,343 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/docMa-handler/pdfTools"
+	"github.com/tochti/gin-gum/gumtest"
+)
+
+func Test_CreateDocFileHandler(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	doc := Doc{Name: "invoice.pdf", Barcode: "invoice"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+	// Name of the upload is taken
+	if err := ioutil.WriteFile(path.Join(files, "invoice.pdf"), []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body := bytes.Buffer{}
+	w := multipart.NewWriter(&body)
+	w.WriteField("role", "attachment")
+	fw, err := w.CreateFormFile("file", "invoice.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("%PDF-1.4 attachment"))
+	w.Close()
+
+	r := gin.New()
+	r.POST("/:docID", func(c *gin.Context) { CreateDocFileHandler(c, db, files) })
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/%v", doc.ID), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expect %v was %v: %v", http.StatusCreated, resp.Code, resp.Body.String())
+	}
+
+	l, err := ReadDocFiles(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := DocFile{
+		ID:       l[0].ID,
+		DocID:    doc.ID,
+		Name:     "invoice-1.pdf",
+		Role:     "attachment",
+		Position: 1,
+	}
+	if len(l) != 1 || l[0] != expect {
+		t.Fatalf("Expect %v was %v", expect, l)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(files, "invoice-1.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "%PDF-1.4 attachment" {
+		t.Fatalf("Unexpected file %s", b)
+	}
+}
+
+func Test_UpdateRemoveDocFile(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	doc := Doc{Name: "contract.pdf", Barcode: "contract"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	f1, err := AddDocFile(db, files, doc.ID, DocFileForm{Name: "a.pdf", File: []byte("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := AddDocFile(db, files, doc.ID, DocFileForm{Name: "b.pdf", File: []byte("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f2.Position != 2 {
+		t.Fatalf("Expect %v was %v", 2, f2.Position)
+	}
+
+	r := gin.New()
+	r.PUT("/:docID/:fileID", func(c *gin.Context) { UpdateDocFileHandler(c, db) })
+	r.DELETE("/:docID/:fileID", func(c *gin.Context) { RemoveDocFileHandler(c, db, files) })
+
+	u := fmt.Sprintf("/%v/%v", doc.ID, f2.ID)
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", u, `{"role": "appendix", "position": 0}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+
+	resp = gumtest.NewRouter(r).ServeHTTP("PUT", u, `{"role": "appendix", "position": 1}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v: %v", http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	u = fmt.Sprintf("/%v/%v", doc.ID, f1.ID)
+	resp = gumtest.NewRouter(r).ServeHTTP("DELETE", u, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+	}
+	if _, err := os.Stat(path.Join(files, "a.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("Expect file to be removed was %v", err)
+	}
+
+	resp = gumtest.NewRouter(r).ServeHTTP("DELETE", u, "")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+
+	l, err := ReadDocFiles(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Role != "appendix" || l[0].Position != 1 {
+		t.Fatalf("Unexpected files %v", l)
+	}
+}
+
+func Test_MergeDocFiles(t *testing.T) {
+	if _, err := exec.LookPath(pdfTools.QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	db := common.InitTestDB(t, AddTables)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	writePDF(t, path.Join(files, "invoice.pdf"), "invoice")
+	doc := Doc{Name: "invoice.pdf", Barcode: "invoice"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	writePDF(t, path.Join(files, "tmp.pdf"), "one", "two")
+	b, err := ioutil.ReadFile(path.Join(files, "tmp.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := AddDocFile(db, files, doc.ID, DocFileForm{Name: "attachment.pdf", File: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := MergeDocFiles(db, files, doc.ID, MergeDocFilesForm{}, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Version != doc.Version+1 {
+		t.Fatalf("Expect version %v was %v", doc.Version+1, merged.Version)
+	}
+
+	n, err := pdfTools.PageCount(path.Join(files, "invoice.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("Expect %v was %v", 3, n)
+	}
+
+	revs, err := ReadRevisions(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[1].Action != RevisionMerge || revs[1].Author != "bob" {
+		t.Fatalf("Unexpected revisions %v", revs)
+	}
+
+	// The merge can be undone, the merged file is still there
+	if _, err := RollbackDoc(db, files, doc.ID, revs[0].Number, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := pdfTools.PageCount(path.Join(files, "invoice.pdf")); n != 1 {
+		t.Fatalf("Expect %v was %v", 1, n)
+	}
+	if _, err := ReadDocFile(db, doc.ID, f.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := pdfTools.PageCount(path.Join(files, f.Name)); n != 2 {
+		t.Fatalf("Expect %v was %v", 2, n)
+	}
+
+	form := MergeDocFilesForm{FileIDs: []int64{f.ID + 1}}
+	if _, err := MergeDocFiles(db, files, doc.ID, form, "bob"); err != ErrNoDocFiles {
+		t.Fatalf("Expect %v was %v", ErrNoDocFiles, err)
+	}
+}
+
+func Test_MergeDocs(t *testing.T) {
+	if _, err := exec.LookPath(pdfTools.QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	writePDF(t, path.Join(files, "part1.pdf"), "part one")
+	writePDF(t, path.Join(files, "part2.pdf"), "part two")
+	d1 := Doc{Name: "part1.pdf", Barcode: "part1"}
+	d2 := Doc{Name: "part2.pdf", Barcode: "part2"}
+	if err := db.Insert(&d1, &d2); err != nil {
+		t.Fatal(err)
+	}
+	label := labels.Label{Name: "Contract"}
+	if err := db.Insert(&label); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(&DocsLabels{DocID: d2.ID, LabelID: label.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(&DocAccountData{DocID: d2.ID, AccountNumber: 4711}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := MergeDocs(db, files, d1.ID, MergeDocsForm{DocIDs: []int64{d2.ID}}, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := pdfTools.PageCount(path.Join(files, "part1.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Expect %v was %v", 2, n)
+	}
+
+	l, err := FindLabelsOfDoc(db, d1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].ID != label.ID {
+		t.Fatalf("Unexpected labels %v", l)
+	}
+
+	c, err := db.SelectInt("SELECT COUNT(*) FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 1 {
+		t.Fatalf("Expect %v was %v", 1, c)
+	}
+	if _, err := os.Stat(path.Join(files, "part2.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("Expect file to be removed was %v", err)
+	}
+
+	a, err := ReadAccountData(db, d1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.AccountNumber != 4711 {
+		t.Fatalf("Expect %v was %v", 4711, a.AccountNumber)
+	}
+
+	revs, err := ReadRevisions(db, d1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].File == "" || revs[1].Action != RevisionMerge {
+		t.Fatalf("Unexpected revisions %v", revs)
+	}
+
+	// The history of the merged doc stays readable
+	revs, err = ReadRevisions(db, d2.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].File == "" {
+		t.Fatalf("Expect archived revision was %v", revs)
+	}
+	p, err := RevisionFilePath(db, files, revs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_MergeDocs_AccountDataConflict(t *testing.T) {
+	if _, err := exec.LookPath(pdfTools.QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	writePDF(t, path.Join(files, "part1.pdf"), "part one")
+	writePDF(t, path.Join(files, "part2.pdf"), "part two")
+	d1 := Doc{Name: "part1.pdf"}
+	d2 := Doc{Name: "part2.pdf"}
+	if err := db.Insert(&d1, &d2); err != nil {
+		t.Fatal(err)
+	}
+	a1 := DocAccountData{DocID: d1.ID, AccountNumber: 1}
+	a2 := DocAccountData{DocID: d2.ID, AccountNumber: 2}
+	if err := db.Insert(&a1, &a2); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := MergeDocs(db, files, d1.ID, MergeDocsForm{DocIDs: []int64{d2.ID}}, "bob")
+	if err != ErrAccountDataConflict {
+		t.Fatalf("Expect %v was %v", ErrAccountDataConflict, err)
+	}
+
+	c, err := db.SelectInt("SELECT COUNT(*) FROM account_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 2 {
+		t.Fatalf("Expect %v was %v", 2, c)
+	}
+	if _, err := os.Stat(path.Join(files, "part2.pdf")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
@@ -14,6 +15,7 @@ import (
 	"github.com/tochti/docMa-handler/accountingData"
 	"github.com/tochti/docMa-handler/common"
 	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/docMa-handler/pdfTools"
 	"github.com/tochti/docMa-handler/valid"
 	"github.com/tochti/gin-gum/gumrest"
 	"gopkg.in/gorp.v1"
@@ -32,15 +34,431 @@ func CreateDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	err = db.Insert(&doc)
+	err = CreateDoc(db, &doc)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
+	common.SetETag(ginCtx, doc.Version)
 	ginCtx.JSON(http.StatusCreated, doc)
 }
 
+// Register a barcode, expects {"barcode": "..."}
+func CreatePlaceholderHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	form := struct {
+		Barcode string `json:"barcode" valid:"required"`
+	}{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	doc, err := CreatePlaceholder(db, form.Barcode)
+	if err != nil {
+		intakeErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, doc)
+}
+
+// Upload a scanned file for a registered barcode. The multipart form has
+// the field file and the optional fields barcode and name.
+func IntakeHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	f, header, err := ginCtx.Request.FormFile("file")
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	form := IntakeForm{
+		Barcode: ginCtx.Request.FormValue("barcode"),
+		Name:    ginCtx.Request.FormValue("name"),
+		File:    b,
+		Author:  common.ReadUser(ginCtx),
+	}
+	if form.Name == "" {
+		form.Name = header.Filename
+	}
+
+	doc, err := Intake(db, files, form)
+	if err != nil {
+		intakeErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, doc)
+}
+
+func intakeErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case ErrUnknownBarcode:
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+	case ErrBarcodeUsed, ErrNameTaken:
+		gumrest.ErrorResponse(c, http.StatusConflict, err)
+	default:
+		gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+}
+
+// Split the file of a doc into new docs, expects a SplitForm
+func SplitDocHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	form := SplitForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r, err := SplitDoc(db, files, id, form)
+	switch {
+	case err == sql.ErrNoRows:
+		gumrest.ErrorResponse(ginCtx, http.StatusNotFound, err)
+		return
+	case err == ErrNoFile:
+		gumrest.ErrorResponse(ginCtx, http.StatusConflict, err)
+		return
+	case err == pdfTools.ErrQPDFMissing:
+		gumrest.ErrorResponse(ginCtx, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, r)
+}
+
+func ReadAllDocFilesHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	l, err := ReadDocFiles(db, id)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+// Upload a file for a doc. The multipart form has the field file and the
+// optional fields role and position.
+func CreateDocFileHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	f, header, err := ginCtx.Request.FormFile("file")
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	form := DocFileForm{
+		Role: ginCtx.Request.FormValue("role"),
+		Name: header.Filename,
+		File: b,
+	}
+	if p := ginCtx.Request.FormValue("position"); p != "" {
+		form.Position, err = strconv.Atoi(p)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	docFile, err := AddDocFile(db, files, id, form)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, docFile)
+}
+
+// Serve the file content
+func ReadDocFileContentHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, fileID, err := readDocFileIDs(ginCtx)
+	if err != nil {
+		return
+	}
+
+	f, err := ReadDocFile(db, id, fileID)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.File(path.Join(files, f.Name))
+}
+
+func UpdateDocFileHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, fileID, err := readDocFileIDs(ginCtx)
+	if err != nil {
+		return
+	}
+
+	u := DocFileUpdate{}
+	if err := ginCtx.BindJSON(&u); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(u); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	f, err := UpdateDocFile(db, id, fileID, u)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, f)
+}
+
+func RemoveDocFileHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, fileID, err := readDocFileIDs(ginCtx)
+	if err != nil {
+		return
+	}
+
+	if err := RemoveDocFile(db, files, id, fileID); err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, nil)
+}
+
+// Merge doc files into the primary file, expects a MergeDocFilesForm
+func MergeDocFilesHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	form := MergeDocFilesForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	doc, err := MergeDocFiles(db, files, id, form, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, doc)
+}
+
+// Merge other docs into the doc, expects a MergeDocsForm
+func MergeDocsHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	form := MergeDocsForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	doc, err := MergeDocs(db, files, id, form, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, doc)
+}
+
+func docErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+	case ErrNoFile, ErrNameTaken, ErrAccountDataConflict:
+		gumrest.ErrorResponse(c, http.StatusConflict, err)
+	case ErrVersionMismatch:
+		gumrest.ErrorResponse(c, http.StatusPreconditionFailed, err)
+	case pdfTools.ErrQPDFMissing:
+		gumrest.ErrorResponse(c, http.StatusServiceUnavailable, err)
+	default:
+		gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+}
+
+func readDocFileIDs(c *gin.Context) (int64, int64, error) {
+	id, err := ReadDocID(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fileID, err := ReadIntParam(c, "fileID")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, int64(fileID), nil
+}
+
+func ReadAllDocRevisionsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	l, err := ReadRevisions(db, id)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+// Changes between the revisions given by the query params from and to
+func DiffDocRevisionsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	revisions := []DocRevision{}
+	for _, p := range []string{"from", "to"} {
+		n, err := strconv.Atoi(ginCtx.Query(p))
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, fmt.Errorf("invalid %v: %v", p, err))
+			return
+		}
+
+		r, err := ReadRevision(db, id, n)
+		if err != nil {
+			docErrorResponse(ginCtx, err)
+			return
+		}
+		revisions = append(revisions, r)
+	}
+
+	ginCtx.JSON(http.StatusOK, DiffRevisions(revisions[0], revisions[1]))
+}
+
+// Serve the file as it was in the revision
+func ReadDocRevisionFileHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, number, err := readRevisionParams(ginCtx)
+	if err != nil {
+		return
+	}
+
+	r, err := ReadRevision(db, id, number)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	p, err := RevisionFilePath(db, files, r)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.File(p)
+}
+
+func RollbackDocRevisionHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, number, err := readRevisionParams(ginCtx)
+	if err != nil {
+		return
+	}
+
+	doc, err := RollbackDoc(db, files, id, number, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, doc)
+}
+
+// Replace the file of the doc with the multipart field file
+func ReplaceDocFileHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	f, _, err := ginCtx.Request.FormFile("file")
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r, err := ReplaceDocFile(db, files, id, b, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func readRevisionParams(c *gin.Context) (int64, int, error) {
+	id, err := ReadDocID(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	number, err := ReadIntParam(c, "revision")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, number, nil
+}
+
 func ReadOneDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 	id, err := ReadDocID(ginCtx)
 	if err != nil {
@@ -54,6 +472,19 @@ func ReadOneDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	common.SetETag(ginCtx, doc.Version)
+
+	if readExpand(ginCtx) {
+		r, err := ExpandDocs(db, []Doc{doc})
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, r[0])
+		return
+	}
+
 	ginCtx.JSON(http.StatusOK, doc)
 
 }
@@ -71,58 +502,81 @@ func RemoveDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	// Remove doc
-	doc := Doc{ID: id}
-	_, err = db.Delete(&doc)
+	err = RemoveDoc(db, id)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	// Remove doc label connections
-	err = RemoveDocLabelConnection(db, id)
+	ginCtx.JSON(http.StatusOK, nil)
+}
+
+// Replace the complete doc, use PatchDocHandler to update single fields.
+// The update only happens if the If-Match header matches the ETag. The
+// name and the placeholder flag of the stored doc are kept.
+func UpdateDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadDocID(ginCtx)
 	if err != nil {
-		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	// Remove doc account data
-	err = RemoveAccountData(db, id)
+	version, err := common.ReadIfMatch(ginCtx)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	// Remove doc numbers
-	err = RemoveDocNumbers(db, id)
-	if err != nil {
+	doc := Doc{}
+	if err := ginCtx.BindJSON(&doc); err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, nil)
+	if err := valid.Struct(doc); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	doc.ID = id
+	doc.Version = version
+	doc, err = ReplaceDoc(db, doc, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	common.SetETag(ginCtx, doc.Version)
+	ginCtx.JSON(http.StatusOK, doc)
 }
 
-// Attention: its only possible to update the complet doc
-func UpdateDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+// Update single fields of the doc with a JSON merge patch, e.g.
+// {"note": "paid"}. The update only happens if the If-Match header
+// matches the ETag.
+func PatchDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 	id, err := ReadDocID(ginCtx)
 	if err != nil {
 		return
 	}
 
-	doc := Doc{}
-	if err := ginCtx.BindJSON(&doc); err != nil {
+	version, err := common.ReadIfMatch(ginCtx)
+	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	doc.ID = id
-	_, err = db.Update(&doc)
+	patch, err := ioutil.ReadAll(ginCtx.Request.Body)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
+	doc, err := PatchDoc(db, id, patch, version, common.ReadUser(ginCtx))
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	common.SetETag(ginCtx, doc.Version)
 	ginCtx.JSON(http.StatusOK, doc)
 }
 
@@ -150,8 +604,9 @@ func UpdateDocNameHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
 		return
 	}
 
-	q := Q("UPDATE %v SET name=? WHERE id=?", DocsTable)
-	_, err = db.Exec(q, doc.Name, id)
+	renamed := *tmpDoc.(*Doc)
+	renamed.Name = doc.Name
+	_, err = UpdateDoc(db, renamed, common.ReadUser(ginCtx))
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
@@ -178,6 +633,11 @@ func CreateDocNumberHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	if _, err := accountingData.ParseDocNumber(docNumber.Number); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
 	if err := db.Insert(&docNumber); err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
@@ -199,6 +659,7 @@ func ReadAllDocNumbersHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	common.SetTotalCount(ginCtx, int64(len(docNumbers)))
 	ginCtx.JSON(http.StatusOK, docNumbers)
 }
 
@@ -262,6 +723,7 @@ func ReadOneDocAccountDataHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	common.SetETag(ginCtx, docAccountData.Version)
 	ginCtx.JSON(http.StatusOK, docAccountData)
 }
 
@@ -271,6 +733,12 @@ func UpdateDocAccountDataHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	version, err := common.ReadIfMatch(ginCtx)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
 	docAccountData := DocAccountData{}
 	if err := ginCtx.BindJSON(&docAccountData); err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
@@ -283,15 +751,47 @@ func UpdateDocAccountDataHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	if _, err := db.Update(&docAccountData); err != nil {
-		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+	docAccountData.Version = version
+	docAccountData, err = UpdateAccountData(db, docAccountData)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
 		return
 	}
 
+	common.SetETag(ginCtx, docAccountData.Version)
 	ginCtx.JSON(http.StatusOK, docAccountData)
 
 }
 
+// Update single fields of the account data with a JSON merge patch
+func PatchDocAccountDataHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadDocID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	version, err := common.ReadIfMatch(ginCtx)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	patch, err := ioutil.ReadAll(ginCtx.Request.Body)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	docAccountData, err := PatchAccountData(db, id, patch, version)
+	if err != nil {
+		docErrorResponse(ginCtx, err)
+		return
+	}
+
+	common.SetETag(ginCtx, docAccountData.Version)
+	ginCtx.JSON(http.StatusOK, docAccountData)
+}
+
 func FindAllLabelsOfDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 	id, err := ReadDocID(ginCtx)
 	if err != nil {
@@ -304,6 +804,7 @@ func FindAllLabelsOfDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	common.SetTotalCount(ginCtx, int64(len(labelList)))
 	ginCtx.JSON(http.StatusOK, labelList)
 }
 
@@ -358,6 +859,7 @@ func FindAllAccountingDataOfDocHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 
 	r := mergeAccountingData(r1, r2)
 
+	common.SetTotalCount(ginCtx, int64(len(r)))
 	ginCtx.JSON(http.StatusOK, r)
 }
 
@@ -373,7 +875,12 @@ func JoinLabelHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	if err := db.Insert(&docsLabels); err != nil {
+	err := JoinLabel(db, docsLabels)
+	if _, ok := err.(labels.GroupConflictError); ok {
+		gumrest.ErrorResponse(ginCtx, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
@@ -404,18 +911,98 @@ func DetachLabelHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 	ginCtx.JSON(http.StatusOK, nil)
 }
 
+// Attach many labels to many docs, expects a BulkLabelsForm
+func BulkAttachLabelsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	form := BulkLabelsForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r, err := AttachLabels(db, form)
+	if _, ok := err.(labels.GroupConflictError); ok {
+		gumrest.ErrorResponse(ginCtx, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+// Detach many labels from many docs, expects a BulkLabelsForm
+func BulkDetachLabelsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	form := BulkLabelsForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := valid.Struct(form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r, err := DetachLabels(db, form)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+// List label connections whose doc or label doesn't exist anymore
+func FindDanglingDocsLabelsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	l, err := FindDanglingDocsLabels(db)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+// Remove dangling label connections and respond with the removed ones
+func RemoveDanglingDocsLabelsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	l, err := RemoveDanglingDocsLabels(db)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
 func FindDocsWithLabelHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 	labelID, err := labels.ReadLabelID(ginCtx)
 	if err != nil {
 		return
 	}
 
-	docs, err := FindDocsWithLabel(db, labelID)
+	page, err := common.ReadPage(ginCtx)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
+	withSubLabels := ginCtx.Query("include_sub_labels") == "true"
+	docs, total, err := FindDocsWithLabel(db, labelID, withSubLabels, page)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, total)
 	ginCtx.JSON(http.StatusOK, docs)
 }
 
@@ -426,13 +1013,46 @@ func SearchDocsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
-	docs, err := SearchDocs(db, searchForm)
+	page, err := common.ReadPage(ginCtx)
 	if err != nil {
 		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, docs)
+	docs, total, err := SearchDocs(db, searchForm, page)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, total)
+
+	var result interface{} = docs
+	if readExpand(ginCtx) {
+		result, err = ExpandDocs(db, docs)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// With facets the docs are wrapped in a search result
+	if ginCtx.Query("facets") == "true" {
+		facets, err := SearchFacets(db, searchForm)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, SearchResult{
+			Docs:   result,
+			Total:  total,
+			Facets: facets,
+		})
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, result)
 }
 
 func mergeAccountingData(a1, a2 []accountingData.AccountingData) []accountingData.AccountingData {
@@ -452,6 +1072,11 @@ func mergeAccountingData(a1, a2 []accountingData.AccountingData) []accountingDat
 	return r
 }
 
+// Related data is embedded if the query parameter expand is true
+func readExpand(c *gin.Context) bool {
+	return c.Query("expand") == "true"
+}
+
 func ReadDocID(c *gin.Context) (int64, error) {
 	i, err := ReadIntParam(c, "docID")
 	return int64(i), err
@@ -2,6 +2,7 @@ package docs
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -145,6 +146,45 @@ func Test_ReadOneDocHandler(t *testing.T) {
 	}
 }
 
+func Test_ReadOneDocHandler_Expand(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables, accountingData.AddTables)
+
+	doc := Doc{
+		ID:   1,
+		Name: "darkmoon.txt",
+	}
+	label := labels.Label{
+		ID:   1,
+		Name: "moon",
+	}
+	docNumber := DocNumber{
+		DocID:  1,
+		Number: "23",
+	}
+
+	err := db.Insert(&doc, &label, &DocsLabels{DocID: 1, LabelID: 1}, &docNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/:docID", gumwrap.Gorp(ReadOneDocHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/1?expand=true", "")
+
+	expectResp := gumtest.JSONResponse{
+		http.StatusOK,
+		ExpandedDoc{
+			Doc:            doc,
+			Labels:         []labels.Label{label},
+			DocNumbers:     []DocNumber{docNumber},
+			AccountingData: []accountingData.AccountingData{},
+		},
+	}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func Test_RemoveDocHandler(t *testing.T) {
 	db := initDB(t)
 
@@ -240,9 +280,10 @@ func Test_UpdateDocHandler(t *testing.T) {
 	r.PUT("/:docID", gumwrap.Gorp(UpdateDocHandler, db))
 	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/1", body)
 
+	// The name stays, the file is renamed with UpdateDocNameHandler
 	doc = Doc{
 		ID:            1,
-		Name:          "fungi.txt",
+		Name:          "darkmoon.txt",
 		Barcode:       "fungi",
 		DateOfScan:    time.Date(2012, time.April, 23, 18, 0, 0, 0, time.UTC),
 		DateOfReceipt: time.Date(2012, time.April, 23, 18, 1, 0, 0, time.UTC),
@@ -255,6 +296,20 @@ func Test_UpdateDocHandler(t *testing.T) {
 	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
 		t.Fatal(err)
 	}
+
+	// A placeholder stays one, so its intake can complete it
+	p, err := CreatePlaceholder(db, "X12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = `{"name": "fungi.txt", "barcode": "X12"}`
+	resp = gumtest.NewRouter(r).ServeHTTP("PUT", fmt.Sprintf("/%v", p.ID), body)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+	}
+	if _, err := FindPlaceholder(db, "X12"); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func Test_UpdateDocNameHandler(t *testing.T) {
@@ -326,6 +381,25 @@ func Test_CreateDocNumberHandler(t *testing.T) {
 
 }
 
+func Test_CreateDocNumberHandler_InvalidNumber(t *testing.T) {
+	db := initDB(t)
+
+	body := `{"doc_id":1,"number":"B-"}`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateDocNumberHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", body)
+	expectResp := gumtest.JSONResponse{
+		http.StatusBadRequest,
+		gumrest.ErrorMessage{
+			Message: `Invalid docnumber! "B-" matches no format`,
+		},
+	}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func Test_CreateDocNumberHandler_MissingDocID(t *testing.T) {
 	db := initDB(t)
 
@@ -798,6 +872,34 @@ func Test_JoinLabelHandler(t *testing.T) {
 	}
 }
 
+func Test_JoinLabelHandler_ExclusiveGroup(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&labels.LabelGroup{ID: 1, Name: "Type", Exclusive: true},
+		&labels.Label{ID: 1, Name: "Invoice", GroupID: 1},
+		&labels.Label{ID: 2, Name: "Contract", GroupID: 1},
+		&DocsLabels{DocID: 1, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(JoinLabelHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", `{"doc_id": 1, "label_id": 2}`)
+
+	expectResp := gumtest.JSONResponse{
+		http.StatusConflict,
+		gumrest.ErrorMessage{
+			Message: "doc already has label 1 of exclusive group Type",
+		},
+	}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func Test_DetachLabelHandler(t *testing.T) {
 	db := common.InitTestDB(t, AddTables, labels.AddTables)
 
@@ -896,6 +998,48 @@ func Test_SearchDocsHandler(t *testing.T) {
 	}
 }
 
+func Test_SearchDocsHandler_Page(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	docs := []Doc{
+		{ID: 1, Name: "a.pdf", Barcode: "1", Note: "Miete"},
+		{ID: 2, Name: "b.pdf", Barcode: "2", Note: "Miete"},
+		{ID: 3, Name: "c.pdf", Barcode: "3", Note: "Miete"},
+	}
+	for i := range docs {
+		if err := db.Insert(&docs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `
+	{
+		"note": {"value": "Miete"}
+	}
+	`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(SearchDocsHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/?sort=id&order=desc&limit=2", body)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+	}
+
+	result := []Doc{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || result[0].ID != 3 || result[1].ID != 2 {
+		t.Fatalf("Expect docs 3 and 2 was %v", result)
+	}
+
+	if total := resp.Header().Get(common.TotalCountHeader); total != "3" {
+		t.Fatalf("Expect %v was %v", "3", total)
+	}
+}
+
 func Test_ReadIntParam(t *testing.T) {
 	passed := false
 	h := func(c *gin.Context) {
@@ -0,0 +1,281 @@
+package docs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tochti/docMa-handler/barcodes"
+	"github.com/tochti/docMa-handler/common"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrUnknownBarcode = errors.New("unknown barcode")
+	ErrBarcodeUsed    = errors.New("barcode is already used")
+	ErrNoBarcode      = barcodes.ErrNoBarcode
+	ErrNameTaken      = errors.New("doc name is already taken")
+
+	// Reader used if no barcode is given with the file. Scans are
+	// searched for the Code128 image of the cover sheet, PDFs of the
+	// cover sheet itself for its text marker.
+	Barcodes BarcodeReader = BarcodeReaders{ImageBarcodeReader{}, MarkerBarcodeReader{}}
+
+	markerRe = regexp.MustCompile(regexp.QuoteMeta(BarcodeMarker) + `([A-Za-z0-9._-]+)`)
+	streamRe = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+)
+
+// Text written next to the barcode on cover sheets
+const BarcodeMarker = "docma-barcode:"
+
+type (
+	BarcodeReader interface {
+		ReadBarcode(pdf []byte) (string, error)
+	}
+
+	// Try the readers in order and return the first barcode found
+	BarcodeReaders []BarcodeReader
+
+	// Decode a Code128 barcode in the images of the PDF, e.g. a scanned
+	// cover sheet. JPEG and deflated images are supported, CCITT and
+	// JBIG2 images are not.
+	ImageBarcodeReader struct{}
+
+	// Find the barcode by the text marker of the cover sheet, e.g.
+	// docma-barcode:A12. The text is searched in the raw and in the
+	// deflated streams of the PDF, images are not decoded.
+	MarkerBarcodeReader struct{}
+
+	IntakeForm struct {
+		Barcode string
+		// Name of the doc file, the barcode is used if it is empty
+		Name string
+		File []byte
+		// Recorded in the revision of the completed doc
+		Author string
+	}
+)
+
+func (readers BarcodeReaders) ReadBarcode(pdf []byte) (string, error) {
+	for _, r := range readers {
+		barcode, err := r.ReadBarcode(pdf)
+		if err == nil {
+			return barcode, nil
+		}
+		if err != ErrNoBarcode {
+			return "", err
+		}
+	}
+
+	return "", ErrNoBarcode
+}
+
+func (ImageBarcodeReader) ReadBarcode(pdf []byte) (string, error) {
+	return barcodes.ReadPDF(pdf)
+}
+
+func (MarkerBarcodeReader) ReadBarcode(pdf []byte) (string, error) {
+	if m := markerRe.FindSubmatch(pdf); m != nil {
+		return string(m[1]), nil
+	}
+
+	for _, s := range streamRe.FindAllSubmatch(pdf, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(s[1]))
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil && len(b) == 0 {
+			continue
+		}
+
+		if m := markerRe.FindSubmatch(b); m != nil {
+			return string(m[1]), nil
+		}
+	}
+
+	return "", ErrNoBarcode
+}
+
+// Find a name which is neither used by a doc nor by a file in the files
+// directory, e.g. scan-1.pdf if scan.pdf is taken
+func FreeName(db gorp.SqlExecutor, files, name string) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; i < 1000; i++ {
+		n := name
+		if i > 0 {
+			n = fmt.Sprintf("%v-%v%v", base, i, ext)
+		}
+
+		q := Q("SELECT COUNT(*) FROM %v WHERE name=?", DocsTable)
+		c, err := db.SelectInt(q, n)
+		if err != nil {
+			return "", err
+		}
+		if c > 0 {
+			continue
+		}
+
+		if _, err := os.Stat(path.Join(files, n)); os.IsNotExist(err) {
+			return n, nil
+		}
+	}
+
+	return "", fmt.Errorf("no free name for %v", name)
+}
+
+// Name of a placeholder doc until its file arrives
+func PlaceholderName(barcode string) string {
+	return "placeholder-" + barcode
+}
+
+// Register a barcode for a doc which is scanned later
+func CreatePlaceholder(db gorp.SqlExecutor, barcode string) (Doc, error) {
+	n, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v WHERE barcode=?", DocsTable), barcode)
+	if err != nil {
+		return Doc{}, err
+	}
+	if n > 0 {
+		return Doc{}, ErrBarcodeUsed
+	}
+
+	doc := Doc{
+		Name:        PlaceholderName(barcode),
+		Barcode:     common.NullString(barcode),
+		Placeholder: true,
+	}
+	if err := db.Insert(&doc); err != nil {
+		return Doc{}, err
+	}
+
+	return doc, nil
+}
+
+// Find the placeholder doc of the barcode
+func FindPlaceholder(db gorp.SqlExecutor, barcode string) (Doc, error) {
+	l := []Doc{}
+	q := Q("SELECT * FROM %v WHERE barcode=?", DocsTable)
+	if _, err := db.Select(&l, q, barcode); err != nil {
+		return Doc{}, err
+	}
+
+	if len(l) == 0 {
+		return Doc{}, ErrUnknownBarcode
+	}
+
+	if !l[0].Placeholder {
+		return Doc{}, ErrBarcodeUsed
+	}
+
+	return l[0], nil
+}
+
+// Store the file in the files directory and attach it to the placeholder
+// doc of the barcode. The barcode is read from the file if the form has
+// none. The create hooks run for the completed doc.
+func Intake(db *gorp.DbMap, files string, form IntakeForm) (Doc, error) {
+	barcode := form.Barcode
+	if barcode == "" {
+		var err error
+		barcode, err = Barcodes.ReadBarcode(form.File)
+		if err != nil {
+			return Doc{}, err
+		}
+	}
+
+	doc, err := FindPlaceholder(db, barcode)
+	if err != nil {
+		return Doc{}, err
+	}
+
+	name := path.Base(form.Name)
+	if form.Name == "" || name == "." || name == "/" {
+		name = barcode + ".pdf"
+	}
+
+	q := Q("SELECT COUNT(*) FROM %v WHERE name=? AND id!=?", DocsTable)
+	n, err := db.SelectInt(q, name, doc.ID)
+	if err != nil {
+		return Doc{}, err
+	}
+	if n > 0 {
+		return Doc{}, ErrNameTaken
+	}
+
+	filename := path.Join(files, name)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return Doc{}, ErrNameTaken
+	}
+	if err != nil {
+		return Doc{}, err
+	}
+	_, err = f.Write(form.File)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(filename)
+		return Doc{}, err
+	}
+
+	doc.Name = name
+	doc.Placeholder = false
+	doc.Version++
+	if doc.DateOfScan.IsZero() {
+		doc.DateOfScan = time.Now()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		os.Remove(filename)
+		return Doc{}, err
+	}
+	rollback := func() {
+		tx.Rollback()
+		os.Remove(filename)
+	}
+
+	// Only one request can complete the placeholder
+	q = Q(`
+	UPDATE %v
+	SET name=?, placeholder=false, date_of_scan=?, version=version+1
+	WHERE id=? AND placeholder=true`, DocsTable)
+	r, err := tx.Exec(q, doc.Name, doc.DateOfScan, doc.ID)
+	if err != nil {
+		rollback()
+		return Doc{}, err
+	}
+	if n, err := r.RowsAffected(); err != nil || n == 0 {
+		rollback()
+		if err != nil {
+			return Doc{}, err
+		}
+		return Doc{}, ErrBarcodeUsed
+	}
+
+	// The history starts with the file, the placeholder had none
+	if _, err := createRevision(tx, doc, form.Author, RevisionIntake); err != nil {
+		rollback()
+		return Doc{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		os.Remove(filename)
+		return Doc{}, err
+	}
+
+	runCreateHooks(db, doc)
+
+	return doc, nil
+}
@@ -0,0 +1,193 @@
+package docs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/barcodes"
+)
+
+func Test_MarkerBarcodeReader(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n(docma-barcode:A12) Tj\nendobj\n")
+	r, err := MarkerBarcodeReader{}.ReadBarcode(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "A12" {
+		t.Fatalf("Expect %v was %v", "A12", r)
+	}
+
+	buf := bytes.Buffer{}
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte("BT (docma-barcode:B7) Tj ET"))
+	w.Close()
+
+	pdf = []byte("%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n")
+	pdf = append(pdf, buf.Bytes()...)
+	pdf = append(pdf, []byte("\nendstream\nendobj\n")...)
+
+	r, err = MarkerBarcodeReader{}.ReadBarcode(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "B7" {
+		t.Fatalf("Expect %v was %v", "B7", r)
+	}
+
+	_, err = MarkerBarcodeReader{}.ReadBarcode([]byte("%PDF-1.4"))
+	if err != ErrNoBarcode {
+		t.Fatalf("Expect %v was %v", ErrNoBarcode, err)
+	}
+}
+
+// PDF with a deflated gray image like a scanner writes it
+func grayImagePDF(w, h int, pix []byte) []byte {
+	buf := bytes.Buffer{}
+	zw := zlib.NewWriter(&buf)
+	zw.Write(pix)
+	zw.Close()
+
+	pdf := []byte(fmt.Sprintf("%%PDF-1.4\n1 0 obj\n<< /Type /XObject /Subtype /Image /Width %v /Height %v /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %v >>\nstream\n", w, h, buf.Len()))
+	pdf = append(pdf, buf.Bytes()...)
+	pdf = append(pdf, []byte("\nendstream\nendobj\n")...)
+
+	return pdf
+}
+
+func Test_Barcodes_ScannedCoverSheet(t *testing.T) {
+	widths, err := barcodes.Code128("7KQ2MX9A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gray scan with the bars 3 pixels per module wide
+	w, h := 600, 100
+	pix := bytes.Repeat([]byte{240}, w*h)
+	for y := 30; y < 70; y++ {
+		x := 50
+		for i, bw := range widths {
+			for dx := 0; dx < bw*3; dx++ {
+				if i%2 == 0 {
+					pix[y*w+x+dx] = 20
+				}
+			}
+			x += bw * 3
+		}
+	}
+
+	r, err := Barcodes.ReadBarcode(grayImagePDF(w, h, pix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "7KQ2MX9A" {
+		t.Fatalf("Expect %v was %v", "7KQ2MX9A", r)
+	}
+
+	// The marker is still read from digital cover sheets
+	r, err = Barcodes.ReadBarcode([]byte("%PDF-1.4\n1 0 obj\n(docma-barcode:A12) Tj\nendobj\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "A12" {
+		t.Fatalf("Expect %v was %v", "A12", r)
+	}
+}
+
+func Test_Intake(t *testing.T) {
+	db := initDB(t)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	if _, err := CreatePlaceholder(db, "A12"); err != nil {
+		t.Fatal(err)
+	}
+
+	form := IntakeForm{
+		Name:   "rechnung.pdf",
+		File:   []byte("%PDF-1.4 docma-barcode:A12"),
+		Author: "bob",
+	}
+	doc, err := Intake(db, files, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Name != "rechnung.pdf" || doc.Barcode != "A12" || doc.Placeholder {
+		t.Fatalf("Unexpected doc %v", doc)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(files, "rechnung.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, form.File) {
+		t.Fatalf("Expect %v was %v", form.File, b)
+	}
+
+	revs, err := ReadRevisions(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].Action != RevisionIntake || revs[0].Name != "rechnung.pdf" || revs[0].Author != "bob" {
+		t.Fatalf("Unexpected revisions %v", revs)
+	}
+
+	_, err = Intake(db, files, form)
+	if err != ErrBarcodeUsed {
+		t.Fatalf("Expect %v was %v", ErrBarcodeUsed, err)
+	}
+
+	_, err = Intake(db, files, IntakeForm{Barcode: "B1", File: form.File})
+	if err != ErrUnknownBarcode {
+		t.Fatalf("Expect %v was %v", ErrUnknownBarcode, err)
+	}
+}
+
+func Test_IntakeHandler_UnknownBarcode(t *testing.T) {
+	db := initDB(t)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	body := bytes.Buffer{}
+	w := multipart.NewWriter(&body)
+	w.WriteField("barcode", "A12")
+	fw, err := w.CreateFormFile("file", "scan.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("%PDF-1.4"))
+	w.Close()
+
+	r := gin.New()
+	r.POST("/", func(c *gin.Context) { IntakeHandler(c, db, files) })
+
+	req, err := http.NewRequest("POST", "/", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func tempFiles(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
@@ -1,21 +1,61 @@
 package docs
 
-import "time"
+import (
+	"time"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+)
 
 var (
 	DocsTable           = "docs"
 	DocNumbersTable     = "doc_numbers"
 	DocAccountDataTable = "account_data"
-	DocsLabelsTable     = "docs_labels"
+	DocsLabelsTable     = labels.DocsLabelsTable
+	DocFilesTable       = "doc_files"
+	DocRevisionsTable   = "doc_revisions"
 )
 
 type Doc struct {
-	ID            int64     `db:"id" json:"id"`
-	Name          string    `db:"name" json:"name" valid:"required"`
-	Barcode       string    `db:"barcode" json:"barcode"`
-	DateOfScan    time.Time `db:"date_of_scan" json:"date_of_scan"`
-	DateOfReceipt time.Time `db:"date_of_receipt" json:"date_of_receipt"`
-	Note          string    `db:"note" json:"note"`
+	ID            int64             `db:"id" json:"id"`
+	Name          string            `db:"name" json:"name" valid:"required"`
+	Barcode       common.NullString `db:"barcode" json:"barcode"`
+	DateOfScan    time.Time         `db:"date_of_scan" json:"date_of_scan"`
+	DateOfReceipt time.Time         `db:"date_of_receipt" json:"date_of_receipt"`
+	Note          string            `db:"note" json:"note"`
+	// Registered barcode waiting for its scanned file
+	Placeholder bool `db:"placeholder" json:"placeholder"`
+	// Incremented with every update, clients get it as ETag
+	Version int64 `db:"version" json:"-"`
+}
+
+// Additional file of a doc, e.g. an attachment of an invoice. The primary
+// file of a doc is still given by Doc.Name.
+type DocFile struct {
+	ID    int64  `db:"id" json:"id"`
+	DocID int64  `db:"doc_id" json:"doc_id"`
+	Name  string `db:"name" json:"name"`
+	// Free text like attachment or appendix
+	Role     string `db:"role" json:"role"`
+	Position int    `db:"position" json:"position"`
+}
+
+// Snapshot of the doc metadata after a change
+type DocRevision struct {
+	ID            int64             `db:"id" json:"id"`
+	DocID         int64             `db:"doc_id" json:"doc_id"`
+	Number        int               `db:"number" json:"number"`
+	CreatedAt     time.Time         `db:"created_at" json:"created_at"`
+	Author        string            `db:"author" json:"author"`
+	Action        string            `db:"action" json:"action"`
+	Name          string            `db:"name" json:"name"`
+	Barcode       common.NullString `db:"barcode" json:"barcode"`
+	DateOfScan    time.Time         `db:"date_of_scan" json:"date_of_scan"`
+	DateOfReceipt time.Time         `db:"date_of_receipt" json:"date_of_receipt"`
+	Note          string            `db:"note" json:"note"`
+	// Archived file of the revision, empty as long as the revision has the
+	// current file of the doc
+	File string `db:"file" json:"-"`
 }
 
 type DocAccountData struct {
@@ -23,6 +63,7 @@ type DocAccountData struct {
 	PeriodFrom    time.Time `db:"period_from" json:"period_from"`
 	PeriodTo      time.Time `db:"period_to" json:"period_to"`
 	AccountNumber int       `db:"account_number" json:"account_number"`
+	Version       int64     `db:"version" json:"-"`
 }
 
 type DocNumber struct {
@@ -31,6 +72,6 @@ type DocNumber struct {
 }
 
 type DocsLabels struct {
-	DocID   int64 `db:"doc_id" json:"doc_id" valid="required,gt=0"`
-	LabelID int64 `db:"label_id" json:"label_id" valid="required,gt=0"`
+	DocID   int64 `db:"doc_id" json:"doc_id" valid:"required,gt=0"`
+	LabelID int64 `db:"label_id" json:"label_id" valid:"required,gt=0"`
 }
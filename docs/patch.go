@@ -0,0 +1,182 @@
+package docs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/tochti/docMa-handler/valid"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrVersionMismatch = errors.New("doc was changed in the meantime")
+	ErrInvalidPatch    = errors.New("patch must be a JSON object")
+
+	// Fields of a doc which can't be patched. The name is the name of the
+	// file and is changed with UpdateDocNameHandler, placeholders are
+	// completed by their intake.
+	readOnlyDocFields = []string{"name", "placeholder"}
+)
+
+// Apply a JSON merge patch (RFC 7396) to the struct v points to. Fields
+// which are null in the patch are reset, unknown fields are an error.
+// Fields without JSON name are reset too and have to be set again.
+func MergePatch(v interface{}, patch []byte) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	target := map[string]interface{}{}
+	if err := json.Unmarshal(b, &target); err != nil {
+		return err
+	}
+
+	p := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return ErrInvalidPatch
+	}
+	for k := range p {
+		if _, ok := target[k]; !ok {
+			return fmt.Errorf("unknown field %v", k)
+		}
+	}
+
+	b, err = json.Marshal(mergePatch(target, p))
+	if err != nil {
+		return err
+	}
+
+	e := reflect.ValueOf(v).Elem()
+	e.Set(reflect.Zero(e.Type()))
+	return json.Unmarshal(b, v)
+}
+
+// Fail if the patch sets or resets one of the fields
+func checkReadOnly(patch []byte, fields []string) error {
+	p := map[string]json.RawMessage{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return ErrInvalidPatch
+	}
+
+	for _, f := range fields {
+		if _, ok := p[f]; ok {
+			return fmt.Errorf("field %v can't be patched", f)
+		}
+	}
+
+	return nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+
+	return t
+}
+
+// Apply the patch to the doc and update it if the result is valid
+func PatchDoc(db *gorp.DbMap, docID int64, patch []byte, version int64, author string) (Doc, error) {
+	if err := checkReadOnly(patch, readOnlyDocFields); err != nil {
+		return Doc{}, err
+	}
+
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), docID)
+	if err != nil {
+		return Doc{}, err
+	}
+
+	if version == 0 {
+		version = doc.Version
+	}
+
+	if err := MergePatch(&doc, patch); err != nil {
+		return Doc{}, err
+	}
+	doc.ID = docID
+	doc.Version = version
+
+	if err := valid.Struct(doc); err != nil {
+		return Doc{}, err
+	}
+
+	return UpdateDoc(db, doc, author)
+}
+
+// Update the account data of a doc. A version other than 0 has to match
+// the version of the stored account data.
+func UpdateAccountData(db *gorp.DbMap, data DocAccountData) (DocAccountData, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return DocAccountData{}, err
+	}
+
+	old := DocAccountData{}
+	q := Q("SELECT * FROM %v WHERE doc_id=? FOR UPDATE", DocAccountDataTable)
+	if err := tx.SelectOne(&old, q, data.DocID); err != nil {
+		tx.Rollback()
+		return DocAccountData{}, err
+	}
+
+	if data.Version == 0 {
+		data.Version = old.Version
+	}
+	if data.Version != old.Version {
+		tx.Rollback()
+		return DocAccountData{}, ErrVersionMismatch
+	}
+
+	if _, err := tx.Update(&data); err != nil {
+		tx.Rollback()
+		return DocAccountData{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DocAccountData{}, err
+	}
+
+	return data, nil
+}
+
+// Apply the patch to the account data of the doc and update it if the
+// result is valid
+func PatchAccountData(db *gorp.DbMap, docID int64, patch []byte, version int64) (DocAccountData, error) {
+	data, err := ReadAccountData(db, docID)
+	if err != nil {
+		return DocAccountData{}, err
+	}
+
+	if version == 0 {
+		version = data.Version
+	}
+
+	if err := MergePatch(&data, patch); err != nil {
+		return DocAccountData{}, err
+	}
+	data.DocID = docID
+	data.Version = version
+
+	if err := valid.Struct(data); err != nil {
+		return DocAccountData{}, err
+	}
+
+	return UpdateAccountData(db, data)
+}
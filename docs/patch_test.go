@@ -0,0 +1,139 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/gin-gum/gumtest"
+)
+
+func Test_MergePatch(t *testing.T) {
+	date := time.Date(2016, time.May, 2, 0, 0, 0, 0, time.UTC)
+	doc := Doc{
+		ID:         1,
+		Name:       "invoice.pdf",
+		Barcode:    "A1",
+		DateOfScan: date,
+		Note:       "open",
+		Version:    3,
+	}
+
+	err := MergePatch(&doc, []byte(`{"note": "paid", "barcode": null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := Doc{
+		ID:         1,
+		Name:       "invoice.pdf",
+		DateOfScan: date,
+		Note:       "paid",
+	}
+	if doc != expect {
+		t.Fatalf("Expect %v was %v", expect, doc)
+	}
+
+	if err := MergePatch(&doc, []byte(`{"colour": "red"}`)); err == nil {
+		t.Fatal("Expect unknown field error")
+	}
+
+	if err := MergePatch(&doc, []byte(`["note"]`)); err != ErrInvalidPatch {
+		t.Fatalf("Expect %v was %v", ErrInvalidPatch, err)
+	}
+}
+
+func Test_PatchDocHandler(t *testing.T) {
+	db := initDB(t)
+
+	doc := Doc{
+		Name:    "invoice.pdf",
+		Barcode: "A1",
+		Note:    "open",
+	}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PATCH("/:docID", func(c *gin.Context) { PatchDocHandler(c, db) })
+
+	patch := func(body, ifMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("PATCH", fmt.Sprintf("/%v", doc.ID), bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := patch(`{"note": "paid"}`, `"1"`)
+	doc.Note = "paid"
+	expectResp := gumtest.JSONResponse{http.StatusOK, doc}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+	if etag := resp.Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf("Expect %v was %v", `"2"`, etag)
+	}
+
+	// Stale version
+	resp = patch(`{"note": "overdue"}`, `"1"`)
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expect %v was %v", http.StatusPreconditionFailed, resp.Code)
+	}
+
+	// The name is changed by renaming, the placeholder flag by intake
+	for _, body := range []string{`{"name": "other.pdf"}`, `{"name": null}`, `{"placeholder": true}`} {
+		resp = patch(body, "")
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Expect %v was %v for %v", http.StatusBadRequest, resp.Code, body)
+		}
+	}
+
+	cur := Doc{}
+	if err := db.SelectOne(&cur, "SELECT * FROM docs WHERE id=?", doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur.Note != "paid" || cur.Name != "invoice.pdf" || cur.Version != 2 {
+		t.Fatalf("Unexpected doc %v", cur)
+	}
+}
+
+func Test_PatchDocAccountDataHandler(t *testing.T) {
+	db := initDB(t)
+
+	accountData := DocAccountData{
+		DocID:         2,
+		PeriodFrom:    time.Date(2012, time.April, 23, 18, 0, 0, 0, time.UTC),
+		PeriodTo:      time.Date(2012, time.April, 23, 18, 1, 0, 0, time.UTC),
+		AccountNumber: 2,
+	}
+	if err := db.Insert(&accountData); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PATCH("/:docID", func(c *gin.Context) { PatchDocAccountDataHandler(c, db) })
+	resp := gumtest.NewRouter(r).ServeHTTP("PATCH", "/2", `{"account_number": 4400}`)
+
+	accountData.AccountNumber = 4400
+	expectResp := gumtest.JSONResponse{http.StatusOK, accountData}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = gumtest.NewRouter(r).ServeHTTP("PATCH", "/3", `{"account_number": 4400}`)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+}
@@ -0,0 +1,491 @@
+package docs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	// Doc before its first recorded change
+	RevisionInitial  = "initial"
+	RevisionUpdate   = "update"
+	RevisionFile     = "file"
+	RevisionRollback = "rollback"
+	// Files or other docs were appended to the file
+	RevisionMerge = "merge"
+	// The file of a placeholder arrived
+	RevisionIntake = "intake"
+)
+
+var (
+	// Directory in the files directory where replaced files are kept
+	RevisionsDir = ".revisions"
+)
+
+// Difference of one field between two revisions. For the file only the
+// numbers of the revisions are given.
+type DocChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Update the metadata of the doc and record the change as revision. A
+// version other than 0 has to match the version of the stored doc. The
+// placeholder flag can't be changed, placeholders are completed by their
+// intake.
+func UpdateDoc(db *gorp.DbMap, doc Doc, author string) (Doc, error) {
+	return updateDoc(db, doc, author, false)
+}
+
+// Replace the metadata of the doc like UpdateDoc but keep the name of the
+// stored doc, its file is renamed with UpdateDocNameHandler
+func ReplaceDoc(db *gorp.DbMap, doc Doc, author string) (Doc, error) {
+	return updateDoc(db, doc, author, true)
+}
+
+func updateDoc(db *gorp.DbMap, doc Doc, author string, keepName bool) (Doc, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Doc{}, err
+	}
+
+	old, err := lockDoc(tx, doc.ID)
+	if err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	if doc.Version == 0 {
+		doc.Version = old.Version
+	}
+	if doc.Version != old.Version {
+		tx.Rollback()
+		return Doc{}, ErrVersionMismatch
+	}
+	doc.Placeholder = old.Placeholder
+	if keepName {
+		doc.Name = old.Name
+	}
+
+	if err := initialRevision(tx, old); err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	if _, err := tx.Update(&doc); err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	if _, err := createRevision(tx, doc, author, RevisionUpdate); err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Doc{}, err
+	}
+
+	return doc, nil
+}
+
+// Replace the file of the doc, the old file is kept for the revisions. The
+// version of the doc is incremented, so clients notice the new file.
+func ReplaceDocFile(db *gorp.DbMap, files string, docID int64, data []byte, author string) (DocRevision, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return DocRevision{}, err
+	}
+
+	doc, err := lockDoc(tx, docID)
+	if err != nil {
+		tx.Rollback()
+		return DocRevision{}, err
+	}
+	if doc.Placeholder {
+		tx.Rollback()
+		return DocRevision{}, ErrNoFile
+	}
+
+	tmp, err := writeTemp(files, data)
+	if err != nil {
+		tx.Rollback()
+		return DocRevision{}, err
+	}
+	defer os.Remove(tmp)
+
+	if err := initialRevision(tx, doc); err != nil {
+		tx.Rollback()
+		return DocRevision{}, err
+	}
+
+	archive, err := archiveFile(tx, files, doc)
+	if err != nil {
+		tx.Rollback()
+		return DocRevision{}, err
+	}
+
+	r, err := createRevision(tx, doc, author, RevisionFile)
+	if err != nil {
+		tx.Rollback()
+		os.Remove(archive)
+		return DocRevision{}, err
+	}
+	if err := bumpVersion(tx, &doc); err != nil {
+		tx.Rollback()
+		os.Remove(archive)
+		return DocRevision{}, err
+	}
+
+	if err := commitFile(tx, tmp, path.Join(files, doc.Name), archive); err != nil {
+		return DocRevision{}, err
+	}
+
+	return r, nil
+}
+
+// Restore the metadata and the file of a revision. The rollback is
+// recorded as a new revision.
+func RollbackDoc(db *gorp.DbMap, files string, docID int64, number int, author string) (Doc, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Doc{}, err
+	}
+
+	cur, err := lockDoc(tx, docID)
+	if err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	r, err := ReadRevision(tx, docID, number)
+	if err != nil {
+		tx.Rollback()
+		return Doc{}, err
+	}
+
+	doc := Doc{
+		ID:            cur.ID,
+		Name:          r.Name,
+		Barcode:       r.Barcode,
+		DateOfScan:    r.DateOfScan,
+		DateOfReceipt: r.DateOfReceipt,
+		Note:          r.Note,
+		Placeholder:   cur.Placeholder,
+		Version:       cur.Version,
+	}
+
+	// Files are only touched after all database changes succeeded
+	restore := ""
+	archive := ""
+	if r.File != "" && !cur.Placeholder {
+		restore, err = copyTemp(files, path.Join(files, RevisionsDir, r.File))
+		if err != nil {
+			tx.Rollback()
+			return Doc{}, err
+		}
+		defer os.Remove(restore)
+
+		archive, err = archiveFile(tx, files, cur)
+		if err != nil {
+			tx.Rollback()
+			return Doc{}, err
+		}
+	}
+	restored := false
+	renamed := false
+	fail := func(err error) (Doc, error) {
+		tx.Rollback()
+		if renamed {
+			os.Rename(path.Join(files, doc.Name), path.Join(files, cur.Name))
+		}
+		switch {
+		case restored:
+			os.Rename(archive, path.Join(files, cur.Name))
+		case archive != "":
+			os.Remove(archive)
+		}
+		return Doc{}, err
+	}
+
+	if doc.Name != cur.Name && !cur.Placeholder {
+		if _, err := os.Stat(path.Join(files, doc.Name)); err == nil {
+			return fail(ErrNameTaken)
+		}
+	}
+
+	if _, err := tx.Update(&doc); err != nil {
+		return fail(err)
+	}
+
+	if _, err := createRevision(tx, doc, author, RevisionRollback); err != nil {
+		return fail(err)
+	}
+
+	if restore != "" {
+		if err := os.Rename(restore, path.Join(files, cur.Name)); err != nil {
+			return fail(err)
+		}
+		restored = true
+	}
+
+	if doc.Name != cur.Name && !cur.Placeholder {
+		err := os.Rename(path.Join(files, cur.Name), path.Join(files, doc.Name))
+		if err != nil {
+			return fail(err)
+		}
+		renamed = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+
+	return doc, nil
+}
+
+// Revisions of the doc, the oldest first
+func ReadRevisions(db gorp.SqlExecutor, docID int64) ([]DocRevision, error) {
+	l := []DocRevision{}
+	q := Q("SELECT * FROM %v WHERE doc_id=? ORDER BY number", DocRevisionsTable)
+	if _, err := db.Select(&l, q, docID); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func ReadRevision(db gorp.SqlExecutor, docID int64, number int) (DocRevision, error) {
+	r := DocRevision{}
+	q := Q("SELECT * FROM %v WHERE doc_id=? AND number=?", DocRevisionsTable)
+	if err := db.SelectOne(&r, q, docID, number); err != nil {
+		return DocRevision{}, err
+	}
+
+	return r, nil
+}
+
+// Path of the file of the revision
+func RevisionFilePath(db gorp.SqlExecutor, files string, r DocRevision) (string, error) {
+	if r.File != "" {
+		return path.Join(files, RevisionsDir, r.File), nil
+	}
+
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), r.DocID)
+	if err != nil {
+		return "", err
+	}
+	if doc.Placeholder {
+		return "", ErrNoFile
+	}
+
+	return path.Join(files, doc.Name), nil
+}
+
+// Changed fields between two revisions
+func DiffRevisions(from, to DocRevision) []DocChange {
+	l := []DocChange{}
+	add := func(field string, a, b interface{}) {
+		if a != b {
+			l = append(l, DocChange{Field: field, From: a, To: b})
+		}
+	}
+
+	add("name", from.Name, to.Name)
+	add("barcode", from.Barcode, to.Barcode)
+	if !from.DateOfScan.Equal(to.DateOfScan) {
+		add("date_of_scan", from.DateOfScan, to.DateOfScan)
+	}
+	if !from.DateOfReceipt.Equal(to.DateOfReceipt) {
+		add("date_of_receipt", from.DateOfReceipt, to.DateOfReceipt)
+	}
+	add("note", from.Note, to.Note)
+	if from.File != to.File {
+		l = append(l, DocChange{Field: "file", From: from.Number, To: to.Number})
+	}
+
+	return l
+}
+
+func lockDoc(tx gorp.SqlExecutor, docID int64) (Doc, error) {
+	doc := Doc{}
+	q := Q("SELECT * FROM %v WHERE id=? FOR UPDATE", DocsTable)
+	if err := tx.SelectOne(&doc, q, docID); err != nil {
+		return Doc{}, err
+	}
+
+	return doc, nil
+}
+
+// Docs created before revisions existed get their state recorded before
+// the first change
+func initialRevision(tx gorp.SqlExecutor, doc Doc) error {
+	q := Q("SELECT COUNT(*) FROM %v WHERE doc_id=?", DocRevisionsTable)
+	n, err := tx.SelectInt(q, doc.ID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	_, err = createRevision(tx, doc, "", RevisionInitial)
+	return err
+}
+
+func createRevision(tx gorp.SqlExecutor, doc Doc, author, action string) (DocRevision, error) {
+	q := Q("SELECT COALESCE(MAX(number), 0) FROM %v WHERE doc_id=?", DocRevisionsTable)
+	n, err := tx.SelectInt(q, doc.ID)
+	if err != nil {
+		return DocRevision{}, err
+	}
+
+	r := DocRevision{
+		DocID:         doc.ID,
+		Number:        int(n) + 1,
+		CreatedAt:     time.Now(),
+		Author:        author,
+		Action:        action,
+		Name:          doc.Name,
+		Barcode:       doc.Barcode,
+		DateOfScan:    doc.DateOfScan,
+		DateOfReceipt: doc.DateOfReceipt,
+		Note:          doc.Note,
+	}
+	if err := tx.Insert(&r); err != nil {
+		return DocRevision{}, err
+	}
+
+	return r, nil
+}
+
+// Increment the version of the doc for changes which don't update its
+// row, e.g. a new file
+func bumpVersion(tx gorp.SqlExecutor, doc *Doc) error {
+	q := Q("UPDATE %v SET version=version+1 WHERE id=?", DocsTable)
+	if _, err := tx.Exec(q, doc.ID); err != nil {
+		return err
+	}
+	doc.Version++
+
+	return nil
+}
+
+// Keep the current file of the doc in the revisions directory and point
+// the revisions which had it to the copy
+func archiveFile(tx gorp.SqlExecutor, files string, doc Doc) (string, error) {
+	q := Q("SELECT COALESCE(MAX(number), 0) FROM %v WHERE doc_id=?", DocRevisionsTable)
+	n, err := tx.SelectInt(q, doc.ID)
+	if err != nil {
+		return "", err
+	}
+
+	dir := path.Join(files, RevisionsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%v-%v%v", doc.ID, n, path.Ext(doc.Name))
+	archive := path.Join(dir, name)
+	if err := linkOrCopy(path.Join(files, doc.Name), archive); err != nil {
+		return "", err
+	}
+
+	q = Q("UPDATE %v SET file=? WHERE doc_id=? AND file=''", DocRevisionsTable)
+	if _, err := tx.Exec(q, name, doc.ID); err != nil {
+		os.Remove(archive)
+		return "", err
+	}
+
+	return archive, nil
+}
+
+// Replace the file of the doc with the new file and commit the
+// transaction. If either fails the archived copy of the old file is put
+// back, so the file on disk matches the database.
+func commitFile(tx *gorp.Transaction, tmp, dst, archive string) error {
+	if err := os.Rename(tmp, dst); err != nil {
+		tx.Rollback()
+		os.Remove(archive)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		os.Rename(archive, dst)
+		return err
+	}
+
+	return nil
+}
+
+// A hard link is enough because files are replaced by renaming
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	tmp, err := copyTemp(path.Dir(dst), src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// Write the data to a temporary file in the directory, so it can be
+// renamed into place
+func writeTemp(dir string, data []byte) (string, error) {
+	f, err := ioutil.TempFile(dir, ".upload")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+func copyTemp(dir, src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	f, err := ioutil.TempFile(dir, ".upload")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, in)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
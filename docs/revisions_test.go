@@ -0,0 +1,193 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/gin-gum/gumtest"
+)
+
+func Test_DiffRevisions(t *testing.T) {
+	from := DocRevision{Number: 1, Name: "a.pdf", Note: "old", File: "1-1.pdf"}
+	to := DocRevision{Number: 3, Name: "a.pdf", Note: "new"}
+
+	expect := []DocChange{
+		{Field: "note", From: "old", To: "new"},
+		{Field: "file", From: 1, To: 3},
+	}
+	if r := DiffRevisions(from, to); !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	if r := DiffRevisions(to, to); len(r) != 0 {
+		t.Fatalf("Expect no changes was %v", r)
+	}
+}
+
+func Test_UpdateDoc_Revisions(t *testing.T) {
+	db := initDB(t)
+
+	doc := Doc{Name: "invoice.pdf", Barcode: "invoice", Note: "v1"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PUT("/:docID", func(c *gin.Context) { UpdateDocHandler(c, db) })
+	r.GET("/:docID/revisions", func(c *gin.Context) { ReadAllDocRevisionsHandler(c, db) })
+	r.GET("/:docID/diff", func(c *gin.Context) { DiffDocRevisionsHandler(c, db) })
+
+	for _, note := range []string{"v2", "v3"} {
+		body := fmt.Sprintf(`{"name": "invoice.pdf", "barcode": "invoice", "note": %q}`, note)
+		req, err := http.NewRequest("PUT", fmt.Sprintf("/%v", doc.ID), bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(common.UserHeader, "alice")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Expect %v was %v: %v", http.StatusOK, resp.Code, resp.Body.String())
+		}
+	}
+
+	l, err := ReadRevisions(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 3 {
+		t.Fatalf("Expect %v was %v", 3, len(l))
+	}
+	for i, e := range []struct{ action, author, note string }{
+		{RevisionInitial, "", "v1"},
+		{RevisionUpdate, "alice", "v2"},
+		{RevisionUpdate, "alice", "v3"},
+	} {
+		if l[i].Number != i+1 || l[i].Action != e.action || l[i].Author != e.author || l[i].Note != e.note {
+			t.Fatalf("Unexpected revision %v", l[i])
+		}
+	}
+
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", fmt.Sprintf("/%v/diff?from=1&to=3", doc.ID), "")
+	expectResp := gumtest.JSONResponse{
+		http.StatusOK,
+		[]DocChange{{Field: "note", From: "v1", To: "v3"}},
+	}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = gumtest.NewRouter(r).ServeHTTP("GET", fmt.Sprintf("/%v/diff?from=1&to=9", doc.ID), "")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func Test_ReplaceDocFile_Rollback(t *testing.T) {
+	db := initDB(t)
+	files := tempFiles(t)
+	defer os.RemoveAll(files)
+
+	doc := Doc{Name: "contract.pdf", Barcode: "contract", Note: "draft"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(files, doc.Name), []byte("version 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body := bytes.Buffer{}
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile("file", "contract.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("version 2"))
+	w.Close()
+
+	r := gin.New()
+	r.PUT("/:docID/file", func(c *gin.Context) { ReplaceDocFileHandler(c, db, files) })
+	r.GET("/:docID/revisions/:revision/file", func(c *gin.Context) { ReadDocRevisionFileHandler(c, db, files) })
+	r.POST("/:docID/revisions/:revision/rollback", func(c *gin.Context) { RollbackDocRevisionHandler(c, db, files) })
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/%v/file", doc.ID), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v: %v", http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	cur := Doc{}
+	if err := db.SelectOne(&cur, "SELECT * FROM docs WHERE id=?", doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur.Version != doc.Version+1 {
+		t.Fatalf("Expect version %v was %v", doc.Version+1, cur.Version)
+	}
+
+	readFile := func(u string) string {
+		resp := gumtest.NewRouter(r).ServeHTTP("GET", u, "")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+		}
+		return resp.Body.String()
+	}
+	if f := readFile(fmt.Sprintf("/%v/revisions/1/file", doc.ID)); f != "version 1" {
+		t.Fatalf("Expect %v was %v", "version 1", f)
+	}
+	if f := readFile(fmt.Sprintf("/%v/revisions/2/file", doc.ID)); f != "version 2" {
+		t.Fatalf("Expect %v was %v", "version 2", f)
+	}
+
+	if _, err := UpdateDoc(db, Doc{ID: doc.ID, Name: doc.Name, Barcode: doc.Barcode, Note: "final"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = gumtest.NewRouter(r).ServeHTTP("POST", fmt.Sprintf("/%v/revisions/1/rollback", doc.ID), "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v: %v", http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	b, err := ioutil.ReadFile(path.Join(files, doc.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "version 1" {
+		t.Fatalf("Expect %v was %s", "version 1", b)
+	}
+
+	cur = Doc{}
+	if err := db.SelectOne(&cur, "SELECT * FROM docs WHERE id=?", doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur.Note != "draft" {
+		t.Fatalf("Expect %v was %v", "draft", cur.Note)
+	}
+
+	// The replaced version is still there
+	if f := readFile(fmt.Sprintf("/%v/revisions/3/file", doc.ID)); f != "version 2" {
+		t.Fatalf("Expect %v was %v", "version 2", f)
+	}
+
+	l, err := ReadRevisions(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 4 || l[3].Action != RevisionRollback {
+		t.Fatalf("Unexpected revisions %v", l)
+	}
+}
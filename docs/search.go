@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tochti/docMa-handler/common"
 	"github.com/tochti/docMa-handler/labels"
 
 	"gopkg.in/gorp.v1"
@@ -17,23 +18,142 @@ type (
 		To   time.Time `json:"to"`
 	}
 
+	// Match text fields by substring or, if Prefix is set, by prefix
+	TextFilter struct {
+		Value  string `json:"value"`
+		Prefix bool   `json:"prefix"`
+	}
+
 	SearchForm struct {
-		Labels     string   `json:"labels"`
-		DocNumbers string   `json:"doc_numbers"`
-		DateOfScan Interval `json:"date_of_scan"`
+		Labels        string     `json:"labels"`
+		DocNumbers    string     `json:"doc_numbers"`
+		DateOfScan    Interval   `json:"date_of_scan"`
+		DateOfReceipt Interval   `json:"date_of_receipt"`
+		Name          TextFilter `json:"name"`
+		Note          TextFilter `json:"note"`
+		Barcode       string     `json:"barcode"`
+		// Find docs with account data for this account number whose
+		// period overlaps AccountPeriod
+		AccountNumber int      `json:"account_number"`
+		AccountPeriod Interval `json:"account_period"`
+		// Find also docs which have a descendant of the labels
+		IncludeSubLabels bool `json:"include_sub_labels"`
+	}
+
+	searchQuery struct {
+		from   string
+		where  string
+		params []interface{}
+	}
+)
+
+var (
+	DocSortFields = common.SortFields{
+		"id":              "docs.id",
+		"name":            "docs.name",
+		"date_of_scan":    "docs.date_of_scan",
+		"date_of_receipt": "docs.date_of_receipt",
 	}
 )
 
-func SearchDocs(db *gorp.DbMap, searchForm SearchForm) ([]Doc, error) {
+func (i Interval) IsZero() bool {
+	return i.From.IsZero() && i.To.IsZero()
+}
+
+func (f SearchForm) IsEmpty() bool {
+	return len(f.Labels) == 0 &&
+		len(f.DocNumbers) == 0 &&
+		f.DateOfScan.IsZero() &&
+		f.DateOfReceipt.IsZero() &&
+		f.Name.Value == "" &&
+		f.Note.Value == "" &&
+		f.Barcode == "" &&
+		f.AccountNumber == 0
+}
+
+// Search docs and return the requested page of the result together with the
+// number of all docs matching the search form
+func SearchDocs(db *gorp.DbMap, searchForm SearchForm, page common.Page) ([]Doc, int64, error) {
 
 	// If no search param set return
-	if len(searchForm.Labels) == 0 &&
-		len(searchForm.DocNumbers) == 0 &&
-		searchForm.DateOfScan.From.IsZero() &&
-		searchForm.DateOfScan.To.IsZero() {
-		return []Doc{}, nil
+	if searchForm.IsEmpty() {
+		return []Doc{}, 0, nil
+	}
+
+	order, err := page.SQL(DocSortFields, "docs.id")
+	if err != nil {
+		return []Doc{}, 0, err
+	}
+
+	searchForm, err = resolveSubLabels(db, searchForm)
+	if err != nil {
+		return []Doc{}, 0, err
+	}
+
+	q := buildSearchQuery(searchForm)
+
+	sel := Q(`
+	SELECT DISTINCT
+		docs.id,
+		docs.name,
+		docs.barcode,
+		docs.date_of_scan,
+		docs.date_of_receipt,
+		docs.note,
+		docs.placeholder,
+		docs.version
+	FROM
+		%v
+	WHERE
+		%v
+	%v`, q.from, q.where, order)
+
+	r := []Doc{}
+	if _, err := db.Select(&r, sel, q.params...); err != nil {
+		return []Doc{}, 0, err
+	}
+
+	count := Q(`
+	SELECT COUNT(DISTINCT docs.id)
+	FROM
+		%v
+	WHERE
+		%v`, q.from, q.where)
+
+	total, err := db.SelectInt(count, q.params...)
+	if err != nil {
+		return []Doc{}, 0, err
+	}
+
+	return r, total, nil
+}
+
+// Replace the labels of the search form with the labels and all their
+// descendants if sub labels should be included
+func resolveSubLabels(db *gorp.DbMap, searchForm SearchForm) (SearchForm, error) {
+	if !searchForm.IncludeSubLabels || len(searchForm.Labels) == 0 {
+		return searchForm, nil
+	}
+
+	l, err := labels.FindLabelsWithDescendants(db, parseQueryString(searchForm.Labels))
+	if err != nil {
+		return SearchForm{}, err
 	}
 
+	// Keep the given names, so a search for unknown labels finds nothing
+	names := parseQueryString(searchForm.Labels)
+	for _, label := range l {
+		names = append(names, label.Name)
+	}
+
+	searchForm.Labels = strings.Join(names, ",")
+	searchForm.IncludeSubLabels = false
+
+	return searchForm, nil
+}
+
+// Create the FROM and WHERE part of the search query
+func buildSearchQuery(searchForm SearchForm) searchQuery {
 	selParam := []interface{}{}
 	filters := []*bytes.Buffer{}
 	froms := []string{}
@@ -86,70 +206,113 @@ func SearchDocs(db *gorp.DbMap, searchForm SearchForm) ([]Doc, error) {
 	}
 
 	// Create date of scan filter
-	if !searchForm.DateOfScan.From.IsZero() || !searchForm.DateOfScan.To.IsZero() {
-		if !searchForm.DateOfScan.From.IsZero() && !searchForm.DateOfScan.To.IsZero() {
-			// Filter from x to y
-			filters = append(filters,
-				bytes.NewBufferString("(docs.date_of_scan BETWEEN ? AND ?)"),
-			)
-			selParam = append(selParam,
-				searchForm.DateOfScan.From,
-				searchForm.DateOfScan.To,
-			)
-		} else if !searchForm.DateOfScan.From.IsZero() {
-			// Filter from X to infinity
-			filters = append(filters,
-				bytes.NewBufferString("(docs.date_of_scan >= ?)"),
-			)
-			selParam = append(selParam, searchForm.DateOfScan.From)
-		} else if !searchForm.DateOfScan.To.IsZero() {
-			// Filter -infinity to X
-			filters = append(filters,
-				bytes.NewBufferString("(docs.date_of_scan <= ?)"),
-			)
-			selParam = append(selParam, searchForm.DateOfScan.To)
-		}
+	if !searchForm.DateOfScan.IsZero() {
+		f, p := intervalFilter("docs.date_of_scan", searchForm.DateOfScan)
+		filters = append(filters, bytes.NewBufferString(f))
+		selParam = append(selParam, p...)
 	}
 
-	sel := bytes.NewBufferString(`
-	SELECT
-		docs.id,
-		docs.name,
-		docs.barcode,
-		docs.date_of_scan,
-		docs.date_of_receipt`)
+	// Create date of receipt filter
+	if !searchForm.DateOfReceipt.IsZero() {
+		f, p := intervalFilter("docs.date_of_receipt", searchForm.DateOfReceipt)
+		filters = append(filters, bytes.NewBufferString(f))
+		selParam = append(selParam, p...)
+	}
 
-	sel.WriteString(fmt.Sprintf(`
-	FROM
-		docs as %v
-	`, DocsTable))
-	if len(froms) == 1 {
-		sel.WriteString(fmt.Sprintf(",%v\n", froms[0]))
-	} else if len(froms) > 1 {
-		for _, v := range froms[:len(froms)-1] {
-			sel.WriteString(fmt.Sprintf(",\n%v\n", v))
+	// Create name filter
+	if searchForm.Name.Value != "" {
+		filters = append(filters,
+			bytes.NewBufferString("(docs.name LIKE ?)"),
+		)
+		selParam = append(selParam, searchForm.Name.likePattern())
+	}
+
+	// Create note filter
+	if searchForm.Note.Value != "" {
+		filters = append(filters,
+			bytes.NewBufferString("(docs.note LIKE ?)"),
+		)
+		selParam = append(selParam, searchForm.Note.likePattern())
+	}
+
+	// Create barcode filter
+	if searchForm.Barcode != "" {
+		filters = append(filters,
+			bytes.NewBufferString("(docs.barcode = ?)"),
+		)
+		selParam = append(selParam, searchForm.Barcode)
+	}
+
+	// Create account data filter
+	if searchForm.AccountNumber != 0 {
+		froms = append(froms, fmt.Sprintf("account_data as %v", DocAccountDataTable))
+
+		filter := bytes.NewBufferString(
+			"(account_data.account_number = ? AND docs.id = account_data.doc_id",
+		)
+		selParam = append(selParam, searchForm.AccountNumber)
+
+		// Periods overlap if the account period starts before the
+		// search interval ends and ends after the search interval starts
+		if !searchForm.AccountPeriod.To.IsZero() {
+			filter.WriteString(" AND account_data.period_from <= ?")
+			selParam = append(selParam, searchForm.AccountPeriod.To)
 		}
-		sel.WriteString(fmt.Sprintf(",%v\n", froms[len(froms)-1]))
+		if !searchForm.AccountPeriod.From.IsZero() {
+			filter.WriteString(" AND account_data.period_to >= ?")
+			selParam = append(selParam, searchForm.AccountPeriod.From)
+		}
+		filter.WriteString(")")
+
+		filters = append(filters, filter)
 	}
 
-	sel.WriteString("WHERE\n")
-	sel.WriteString(filters[0].String())
+	froms = append([]string{fmt.Sprintf("docs as %v", DocsTable)}, froms...)
+
+	where := bytes.NewBufferString(filters[0].String())
 	for _, v := range filters[1:] {
-		sel.WriteString(fmt.Sprintf("\t\nAND %v", v.String()))
+		where.WriteString(fmt.Sprintf("\n\tAND %v", v.String()))
 	}
 
-	sel.WriteString("\nGROUP BY docs.name")
+	return searchQuery{
+		from:   strings.Join(froms, ",\n\t\t"),
+		where:  where.String(),
+		params: selParam,
+	}
+}
 
-	//fmt.Println(sel)
-	//fmt.Println(selParam)
+// Create a filter for a column with an interval which is open
+// at one side if From or To is zero
+func intervalFilter(column string, i Interval) (string, []interface{}) {
+	switch {
+	case !i.From.IsZero() && !i.To.IsZero():
+		// Filter from x to y
+		return fmt.Sprintf("(%v BETWEEN ? AND ?)", column),
+			[]interface{}{i.From, i.To}
+	case !i.From.IsZero():
+		// Filter from X to infinity
+		return fmt.Sprintf("(%v >= ?)", column),
+			[]interface{}{i.From}
+	default:
+		// Filter -infinity to X
+		return fmt.Sprintf("(%v <= ?)", column),
+			[]interface{}{i.To}
+	}
+}
 
-	r := []Doc{}
-	_, err := db.Select(&r, sel.String(), selParam...)
-	if err != nil {
-		return []Doc{}, err
+func (f TextFilter) likePattern() string {
+	v := escapeLike(f.Value)
+	if f.Prefix {
+		return v + "%"
 	}
 
-	return r, nil
+	return "%" + v + "%"
+}
+
+// Escape the LIKE wildcards so user input is matched literally
+func escapeLike(str string) string {
+	r := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return r.Replace(str)
 }
 
 func dateSQLFormat(t time.Time) string {
@@ -69,7 +69,7 @@ func Test_SearchDocs_ByLabels(t *testing.T) {
 		Labels: "l1,l2",
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -122,7 +122,7 @@ func Test_SearchDocs_FromDateOfScan(t *testing.T) {
 		},
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -175,7 +175,7 @@ func Test_SearchDocs_ToDateOfScan(t *testing.T) {
 		},
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -229,7 +229,7 @@ func Test_SearchDocs_BetweenDates(t *testing.T) {
 		},
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -291,7 +291,7 @@ func Test_SearchDocs_ByDocNumbers(t *testing.T) {
 		},
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -400,7 +400,7 @@ func Test_SearchDocs(t *testing.T) {
 		},
 	}
 
-	r, err := SearchDocs(db, searchForm)
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
 
 	if err != nil {
 		t.Fatal(err)
@@ -418,6 +418,258 @@ func Test_SearchDocs(t *testing.T) {
 	}
 }
 
+func Test_SearchDocs_ByDateOfReceipt(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+
+	d := gumtest.SimpleNow()
+	doc1 := Doc{
+		ID:            1,
+		Name:          "FindMe.pdf",
+		DateOfScan:    d,
+		DateOfReceipt: d,
+	}
+
+	if err := db.Insert(&doc1); err != nil {
+		t.Fatal(err)
+	}
+
+	d2 := gumtest.SimpleNow().Add(-48 * time.Hour)
+	err := db.Insert(
+		&Doc{
+			Name:          "DontFindMe1.pdf",
+			Barcode:       "1",
+			DateOfScan:    d,
+			DateOfReceipt: d2,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchForm := SearchForm{
+		DateOfReceipt: Interval{
+			From: d.Add(-24 * time.Hour),
+		},
+	}
+
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 {
+		t.Fatal("Expect len 1 was len", len(r))
+	}
+
+	if doc1.Name != r[0].Name {
+		t.Fatalf("Expect %v was %v", doc1, r[0])
+	}
+}
+
+func Test_SearchDocs_ByNameAndNote(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+
+	doc1 := Doc{
+		ID:   1,
+		Name: "strato_2016_01.pdf",
+		Note: "Rechnung 100% Hosting",
+	}
+
+	if err := db.Insert(&doc1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Insert(
+		&Doc{
+			Name:    "rechnung_strato.pdf",
+			Barcode: "1",
+			Note:    "Rechnung 100% Hosting",
+		},
+		&Doc{
+			Name:    "strato_2016_02.pdf",
+			Barcode: "2",
+			Note:    "Rechnung 1000 Hosting",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchForm := SearchForm{
+		Name: TextFilter{
+			Value:  "strato",
+			Prefix: true,
+		},
+		Note: TextFilter{
+			Value: "100%",
+		},
+	}
+
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 {
+		t.Fatal("Expect len 1 was len", len(r))
+	}
+
+	if doc1.Name != r[0].Name {
+		t.Fatalf("Expect %v was %v", doc1, r[0])
+	}
+}
+
+func Test_SearchDocs_ByBarcode(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+
+	doc1 := Doc{
+		ID:      1,
+		Name:    "FindMe.pdf",
+		Barcode: "4711",
+	}
+
+	if err := db.Insert(&doc1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Insert(
+		&Doc{
+			Name:    "DontFindMe1.pdf",
+			Barcode: "47111",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, err := SearchDocs(db, SearchForm{Barcode: "4711"}, common.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 {
+		t.Fatal("Expect len 1 was len", len(r))
+	}
+
+	if doc1.Name != r[0].Name {
+		t.Fatalf("Expect %v was %v", doc1, r[0])
+	}
+}
+
+func Test_SearchDocs_ByAccountNumber(t *testing.T) {
+	db := common.InitTestDB(t, AddTables)
+
+	d := gumtest.SimpleNow()
+	doc1 := Doc{
+		ID:   1,
+		Name: "FindMe.pdf",
+	}
+
+	accountData1 := DocAccountData{
+		DocID:         doc1.ID,
+		PeriodFrom:    d.Add(-48 * time.Hour),
+		PeriodTo:      d,
+		AccountNumber: 1400,
+	}
+
+	if err := db.Insert(&doc1, &accountData1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add docs that we shouldn't find
+	err := db.Insert(
+		&Doc{
+			ID:      2,
+			Name:    "DontFindMe1.pdf",
+			Barcode: "1",
+		},
+		&DocAccountData{
+			DocID:         2,
+			PeriodFrom:    d.Add(24 * time.Hour),
+			PeriodTo:      d.Add(48 * time.Hour),
+			AccountNumber: 1400,
+		},
+		&Doc{
+			ID:      3,
+			Name:    "DontFindMe2.pdf",
+			Barcode: "2",
+		},
+		&DocAccountData{
+			DocID:         3,
+			PeriodFrom:    d.Add(-48 * time.Hour),
+			PeriodTo:      d,
+			AccountNumber: 1500,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchForm := SearchForm{
+		AccountNumber: 1400,
+		AccountPeriod: Interval{
+			From: d.Add(-24 * time.Hour),
+			To:   d.Add(12 * time.Hour),
+		},
+	}
+
+	r, _, err := SearchDocs(db, searchForm, common.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 {
+		t.Fatal("Expect len 1 was len", len(r))
+	}
+
+	if doc1.Name != r[0].Name {
+		t.Fatalf("Expect %v was %v", doc1, r[0])
+	}
+}
+
+func Test_SearchDocs_IncludeSubLabels(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	err := db.Insert(
+		&labels.Label{ID: 1, Name: "Finanzen"},
+		&labels.Label{ID: 2, Name: "Bank", ParentID: 1},
+		&labels.Label{ID: 3, Name: "Sparkasse", ParentID: 2},
+		&labels.Label{ID: 4, Name: "Miete"},
+		&Doc{ID: 1, Name: "FindMe1.pdf", Barcode: "1"},
+		&Doc{ID: 2, Name: "FindMe2.pdf", Barcode: "2"},
+		&Doc{ID: 3, Name: "DontFindMe.pdf", Barcode: "3"},
+		&DocsLabels{DocID: 1, LabelID: 2},
+		&DocsLabels{DocID: 2, LabelID: 3},
+		&DocsLabels{DocID: 3, LabelID: 4},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchForm := SearchForm{
+		Labels:           "Finanzen",
+		IncludeSubLabels: true,
+	}
+
+	r, total, err := SearchDocs(db, searchForm, common.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 2 || total != 2 || r[0].ID != 1 || r[1].ID != 2 {
+		t.Fatalf("Expect docs 1 and 2 was %v", r)
+	}
+}
+
+func Test_EscapeLike(t *testing.T) {
+	r := escapeLike(`100%_\`)
+
+	expect := `100\%\_\\`
+	if r != expect {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
 func Test_ParseQueryString_None(t *testing.T) {
 	l := parseQueryString("")
 
@@ -0,0 +1,251 @@
+package docs
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tochti/docMa-handler/barcodes"
+	"github.com/tochti/docMa-handler/pdfTools"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	SeparatorBlank   = "blank"
+	SeparatorBarcode = "barcode"
+)
+
+var (
+	ErrNoFile           = errors.New("doc has no file")
+	ErrNoSplit          = errors.New("ranges or separator are required")
+	ErrUnknownSeparator = errors.New("separator must be blank or barcode")
+	ErrNoPages          = errors.New("no pages left to split")
+
+	// A page is blank if its images have a lower share of dark pixels,
+	// scanners leave some dust and shadows at the edges
+	BlankPageInk = 0.002
+	// Pages without images which can be decoded, e.g. digital files or
+	// CCITT scans, are blank if their single page file is smaller
+	BlankPageSize = 4096
+	// Share of the width and height at each edge which is ignored
+	blankPageMargin = 0.05
+)
+
+type SplitForm struct {
+	// Page ranges like "1-2,3-5", every range becomes a new doc
+	Ranges string `json:"ranges"`
+	// Split at blank pages or at pages with a barcode instead of ranges.
+	// The separator pages are dropped.
+	Separator      string `json:"separator"`
+	DeleteOriginal bool   `json:"delete_original"`
+}
+
+// Split the file of a doc into new docs. The new docs get the note, the
+// dates and the labels of the original. All docs are created or none.
+func SplitDoc(db *gorp.DbMap, files string, docID int64, form SplitForm) ([]Doc, error) {
+	doc := Doc{}
+	err := db.SelectOne(&doc, Q("SELECT * FROM %v WHERE id=?", DocsTable), docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Placeholder {
+		return nil, ErrNoFile
+	}
+
+	file := path.Join(files, doc.Name)
+	ranges, err := splitRanges(file, form)
+	if err != nil {
+		return nil, err
+	}
+
+	docLabels, err := FindLabelsOfDoc(db, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	created := []Doc{}
+	written := []string{}
+	cleanup := func() {
+		tx.Rollback()
+		for _, f := range written {
+			os.Remove(f)
+		}
+	}
+
+	ext := path.Ext(doc.Name)
+	base := strings.TrimSuffix(doc.Name, ext)
+	for i, r := range ranges {
+		name, err := FreeName(tx, files, fmt.Sprintf("%v-%v%v", base, i+1, ext))
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		out := path.Join(files, name)
+		err = pdfTools.ExtractPages(file, []pdfTools.PageRange{r}, out)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		written = append(written, out)
+
+		d := Doc{
+			Name:          name,
+			DateOfScan:    doc.DateOfScan,
+			DateOfReceipt: doc.DateOfReceipt,
+			Note:          doc.Note,
+		}
+		if err := tx.Insert(&d); err != nil {
+			cleanup()
+			return nil, err
+		}
+		created = append(created, d)
+
+		for _, l := range docLabels {
+			if err := tx.Insert(&DocsLabels{DocID: d.ID, LabelID: l.ID}); err != nil {
+				cleanup()
+				return nil, err
+			}
+		}
+	}
+
+	if form.DeleteOriginal {
+		if err := RemoveDoc(tx, doc.ID); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	if form.DeleteOriginal {
+		os.Remove(file)
+	}
+
+	for _, d := range created {
+		runCreateHooks(db, d)
+	}
+
+	return created, nil
+}
+
+// Page ranges of the new docs
+func splitRanges(file string, form SplitForm) ([]pdfTools.PageRange, error) {
+	pages, err := pdfTools.PageCount(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if form.Ranges != "" {
+		ranges, err := pdfTools.ParsePageRanges(form.Ranges)
+		if err != nil {
+			return nil, err
+		}
+		if err := pdfTools.CheckPageRanges(ranges, pages); err != nil {
+			return nil, err
+		}
+		return ranges, nil
+	}
+
+	var isSeparator func(page []byte) bool
+	switch form.Separator {
+	case "":
+		return nil, ErrNoSplit
+	case SeparatorBlank:
+		isSeparator = isBlankPage
+	case SeparatorBarcode:
+		isSeparator = func(page []byte) bool {
+			_, err := Barcodes.ReadBarcode(page)
+			return err == nil
+		}
+	default:
+		return nil, ErrUnknownSeparator
+	}
+
+	separators := make([]bool, pages)
+	for i := range separators {
+		page, err := pdfTools.ReadPage(file, i+1)
+		if err != nil {
+			return nil, err
+		}
+		separators[i] = isSeparator(page)
+	}
+
+	return separatorRanges(separators)
+}
+
+// Group the pages between separator pages, separators[i] is true if page
+// i+1 is a separator
+func separatorRanges(separators []bool) ([]pdfTools.PageRange, error) {
+	ranges := []pdfTools.PageRange{}
+	from := 0
+	for i, sep := range separators {
+		page := i + 1
+		switch {
+		case sep && from > 0:
+			ranges = append(ranges, pdfTools.PageRange{From: from, To: page - 1})
+			from = 0
+		case !sep && from == 0:
+			from = page
+		}
+	}
+	if from > 0 {
+		ranges = append(ranges, pdfTools.PageRange{From: from, To: len(separators)})
+	}
+
+	if len(ranges) == 0 {
+		return nil, ErrNoPages
+	}
+
+	return ranges, nil
+}
+
+// Blank pages are found by the dark pixels of their images
+func isBlankPage(page []byte) bool {
+	images := barcodes.PDFImages(page)
+	if len(images) == 0 {
+		return len(page) < BlankPageSize
+	}
+
+	for _, img := range images {
+		if ink(img) >= BlankPageInk {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Share of dark pixels of the image without its margins
+func ink(img image.Image) float64 {
+	b := img.Bounds()
+	mx := int(float64(b.Dx()) * blankPageMargin)
+	my := int(float64(b.Dy()) * blankPageMargin)
+
+	dark, total := 0, 0
+	for y := b.Min.Y + my; y < b.Max.Y-my; y++ {
+		for x := b.Min.X + mx; x < b.Max.X-mx; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+				dark++
+			}
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	return float64(dark) / float64(total)
+}
@@ -0,0 +1,226 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/docMa-handler/pdfTools"
+	"github.com/tochti/gin-gum/gumtest"
+)
+
+func Test_separatorRanges(t *testing.T) {
+	r, err := separatorRanges([]bool{true, false, false, true, true, false, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []pdfTools.PageRange{{2, 3}, {6, 6}}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	r, err = separatorRanges([]bool{false, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = []pdfTools.PageRange{{1, 2}}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	if _, err := separatorRanges([]bool{true}); err != ErrNoPages {
+		t.Fatalf("Expect %v was %v", ErrNoPages, err)
+	}
+}
+
+func Test_isBlankPage(t *testing.T) {
+	w, h := 200, 300
+	pix := bytes.Repeat([]byte{235}, w*h)
+
+	// Dust and a dark scanner edge
+	pix[150*w+100] = 0
+	for y := 0; y < h; y++ {
+		pix[y*w] = 0
+		pix[y*w+1] = 0
+	}
+	if !isBlankPage(grayImagePDF(w, h, pix)) {
+		t.Fatal("Expect blank page")
+	}
+
+	// A few lines of text
+	for y := 50; y < 250; y += 20 {
+		for x := 30; x < 170; x++ {
+			pix[y*w+x] = 20
+			pix[(y+1)*w+x] = 20
+		}
+	}
+	if isBlankPage(grayImagePDF(w, h, pix)) {
+		t.Fatal("Expect page with text")
+	}
+
+	// Pages without images fall back to the size of the file
+	if !isBlankPage([]byte("%PDF-1.4\n")) {
+		t.Fatal("Expect small page to be blank")
+	}
+	if isBlankPage(bytes.Repeat([]byte("BT (text) Tj ET\n"), BlankPageSize)) {
+		t.Fatal("Expect large page not to be blank")
+	}
+}
+
+// Write a PDF with one page per text
+func writePDF(t *testing.T, file string, texts ...string) {
+	objs := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := []string{}
+	for _, text := range texts {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%v) Tj ET", text)
+		objs = append(objs,
+			fmt.Sprintf("<< /Length %v >>\nstream\n%v\nendstream", len(content), content),
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents %v 0 R >>", len(objs)+1),
+		)
+		kids = append(kids, fmt.Sprintf("%v 0 R", len(objs)))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), len(kids))
+
+	b := &bytes.Buffer{}
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, o := range objs {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(b, "%v 0 obj\n%v\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(b, "xref\n0 %v\n0000000000 65535 f \n", len(objs)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(b, "trailer\n<< /Size %v /Root 1 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(objs)+1, xref)
+
+	if err := ioutil.WriteFile(file, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SplitDocHandler(t *testing.T) {
+	if _, err := exec.LookPath(pdfTools.QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	files, err := ioutil.TempDir("", "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(files)
+
+	writePDF(t, path.Join(files, "scan.pdf"),
+		"docma-barcode:A1", "receipt one", "docma-barcode:A2", "receipt two", "receipt two")
+
+	doc := Doc{Name: "scan.pdf", Barcode: "scan", Note: "batch"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+	label := labels.Label{Name: "Inbox"}
+	if err := db.Insert(&label); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(&DocsLabels{DocID: doc.ID, LabelID: label.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"separator": "barcode", "delete_original": true}`
+	r := gin.New()
+	r.POST("/:docID", func(c *gin.Context) {
+		SplitDocHandler(c, db, files)
+	})
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", fmt.Sprintf("/%v", doc.ID), body)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expect %v was %v: %v", http.StatusCreated, resp.Code, resp.Body.String())
+	}
+
+	created := []Doc{}
+	if _, err := db.Select(&created, "SELECT * FROM docs ORDER BY name"); err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || created[0].Name != "scan-1.pdf" || created[1].Name != "scan-2.pdf" {
+		t.Fatalf("Unexpected docs %v", created)
+	}
+
+	for i, pages := range []int{1, 2} {
+		d := created[i]
+		if d.Note != "batch" {
+			t.Fatalf("Expect %v was %v", "batch", d.Note)
+		}
+
+		n, err := pdfTools.PageCount(path.Join(files, d.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != pages {
+			t.Fatalf("Expect %v was %v", pages, n)
+		}
+
+		l, err := FindLabelsOfDoc(db, d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 1 || l[0].ID != label.ID {
+			t.Fatalf("Unexpected labels %v", l)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(files, "scan.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("Expect original file to be removed was %v", err)
+	}
+}
+
+func Test_SplitDocHandler_InvalidRange(t *testing.T) {
+	if _, err := exec.LookPath(pdfTools.QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	db := common.InitTestDB(t, AddTables, labels.AddTables)
+
+	files, err := ioutil.TempDir("", "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(files)
+
+	writePDF(t, path.Join(files, "scan.pdf"), "one", "two")
+
+	doc := Doc{Name: "scan.pdf", Barcode: "scan"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/:docID", func(c *gin.Context) {
+		SplitDocHandler(c, db, files)
+	})
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", fmt.Sprintf("/%v", doc.ID), `{"ranges": "1,2-3"}`)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+
+	n, err := db.SelectInt("SELECT COUNT(*) FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Expect %v was %v", 1, n)
+	}
+}
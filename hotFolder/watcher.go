@@ -0,0 +1,335 @@
+// Package hotFolder imports the files a scanner drops into a directory.
+package hotFolder
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/settings"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	DefaultInterval    = 2 * time.Second
+	DefaultStablePolls = 2
+)
+
+const (
+	IntervalSetting    = "hotFolder.interval"
+	StablePollsSetting = "hotFolder.stablePolls"
+)
+
+func init() {
+	settings.Register(settings.Setting{
+		Name:        IntervalSetting,
+		Kind:        settings.KindDuration,
+		Default:     DefaultInterval.String(),
+		Description: "Time between two polls of the hot folder",
+		Validate:    settings.MinDuration(100 * time.Millisecond),
+	})
+	settings.Register(settings.Setting{
+		Name:        StablePollsSetting,
+		Kind:        settings.KindInt,
+		Default:     strconv.Itoa(DefaultStablePolls),
+		Description: "Polls a file has to stay unchanged before it is imported",
+		Validate:    settings.MinInt(1),
+	})
+}
+
+type (
+	// Polls Dir for PDF files. A file is imported once its size and
+	// modification time didn't change for StablePolls polls, so files
+	// still written by the scanner are left alone.
+	Watcher struct {
+		DB         *gorp.DbMap
+		Dir        string
+		Files      string
+		DeadLetter string
+		// Names of labels attached to every imported doc, missing labels
+		// are created
+		Labels      []string
+		Interval    time.Duration
+		StablePolls int
+
+		files map[string]fileState
+		stop  chan struct{}
+		done  chan struct{}
+		once  sync.Once
+	}
+
+	fileState struct {
+		size    int64
+		modTime time.Time
+		stable  int
+	}
+)
+
+func NewWatcher(db *gorp.DbMap, specs common.Specs) *Watcher {
+	labels := []string{}
+	for _, l := range strings.Split(specs.HotFolderLabels, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+
+	w := &Watcher{
+		DB:          db,
+		Dir:         specs.HotFolder,
+		Files:       specs.Files,
+		DeadLetter:  specs.DeadLetter,
+		Labels:      labels,
+		Interval:    DefaultInterval,
+		StablePolls: DefaultStablePolls,
+	}
+
+	if d, err := settings.Duration(db, IntervalSetting); err == nil {
+		w.Interval = d
+	} else {
+		log.Printf("hot folder uses default interval: %v", err)
+	}
+	if n, err := settings.Int(db, StablePollsSetting); err == nil {
+		w.StablePolls = n
+	} else {
+		log.Printf("hot folder uses default stable polls: %v", err)
+	}
+
+	return w
+}
+
+// Poll the directory in the background until Stop is called
+func (w *Watcher) Start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		t := time.NewTicker(w.Interval)
+		defer t.Stop()
+
+		for {
+			if err := w.Poll(); err != nil {
+				log.Printf("hot folder %v: %v", w.Dir, err)
+			}
+
+			select {
+			case <-w.stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop polling and wait until the running import is finished
+func (w *Watcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// Check the directory once and import all files which are complete
+func (w *Watcher) Poll() error {
+	if w.files == nil {
+		w.files = map[string]fileState{}
+	}
+
+	infos, err := ioutil.ReadDir(w.Dir)
+	if err != nil {
+		return err
+	}
+
+	present := map[string]bool{}
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") ||
+			strings.ToLower(path.Ext(name)) != ".pdf" {
+			continue
+		}
+		present[name] = true
+
+		last, ok := w.files[name]
+		if !ok || last.size != info.Size() || !last.modTime.Equal(info.ModTime()) {
+			w.files[name] = fileState{size: info.Size(), modTime: info.ModTime()}
+			continue
+		}
+
+		last.stable++
+		w.files[name] = last
+		if last.stable < w.StablePolls {
+			continue
+		}
+
+		delete(w.files, name)
+		if err := w.importFile(info); err != nil {
+			log.Printf("hot folder import of %v failed: %v", name, err)
+			w.deadLetter(path.Join(w.Dir, name), err)
+		}
+	}
+
+	// Forget files which were removed by someone else
+	for name := range w.files {
+		if !present[name] {
+			delete(w.files, name)
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) importFile(info os.FileInfo) error {
+	src := path.Join(w.Dir, info.Name())
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	name, err := docs.FreeName(w.DB, w.Files, info.Name())
+	if err != nil {
+		return err
+	}
+
+	// Scans with a cover sheet complete their placeholder doc. Other
+	// barcodes, e.g. of a parcel service on an invoice, are ignored and
+	// the scan is imported as a doc of its own.
+	if barcode, ok := w.placeholderBarcode(b); ok {
+		doc, err := docs.Intake(w.DB, w.Files, docs.IntakeForm{
+			Barcode: barcode,
+			Name:    name,
+			File:    b,
+		})
+		if err != nil {
+			return fmt.Errorf("barcode %v: %v", barcode, err)
+		}
+		w.attachLabels(doc)
+
+		// A scan left behind would be imported again, so it goes to the
+		// dead letter directory with the error
+		if err := os.Remove(src); err != nil {
+			return fmt.Errorf("barcode %v imported as doc %v: %v", barcode, doc.ID, err)
+		}
+		return nil
+	}
+
+	dst := path.Join(w.Files, name)
+	if err := moveFile(src, dst); err != nil {
+		return err
+	}
+
+	doc := docs.Doc{
+		Name:       name,
+		DateOfScan: info.ModTime(),
+	}
+	if err := docs.CreateDoc(w.DB, &doc); err != nil {
+		// Move the file back so the dead letter gets it
+		if err1 := moveFile(dst, src); err1 != nil {
+			log.Printf("hot folder can't move %v back: %v", dst, err1)
+		}
+		return err
+	}
+
+	w.attachLabels(doc)
+	return nil
+}
+
+// Barcode of the file if it belongs to a placeholder doc. A barcode of a
+// completed placeholder counts too, so the scan is reported as dead letter
+// instead of being imported twice.
+func (w *Watcher) placeholderBarcode(pdf []byte) (string, bool) {
+	barcode, err := docs.Barcodes.ReadBarcode(pdf)
+	if err != nil {
+		return "", false
+	}
+
+	_, err = docs.FindPlaceholder(w.DB, barcode)
+	if err == docs.ErrUnknownBarcode {
+		return "", false
+	}
+
+	return barcode, true
+}
+
+// The doc exists at this point, so failing labels are only logged
+func (w *Watcher) attachLabels(doc docs.Doc) {
+	if len(w.Labels) == 0 {
+		return
+	}
+
+	form := docs.BulkLabelsForm{
+		DocIDs:     []int64{doc.ID},
+		LabelNames: w.Labels,
+	}
+	if _, err := docs.AttachLabels(w.DB, form); err != nil {
+		log.Printf("hot folder can't attach labels to doc %v: %v", doc.ID, err)
+	}
+}
+
+// Move the file into the dead letter directory together with a file
+// explaining the error
+func (w *Watcher) deadLetter(src string, cause error) {
+	if w.DeadLetter == "" {
+		return
+	}
+
+	name := path.Base(src)
+	dst := path.Join(w.DeadLetter, name)
+	if _, err := os.Stat(dst); err == nil {
+		name = fmt.Sprintf("%v-%v", time.Now().Format("20060102150405"), name)
+		dst = path.Join(w.DeadLetter, name)
+	}
+
+	if err := moveFile(src, dst); err != nil {
+		log.Printf("hot folder can't move %v to dead letter: %v", src, err)
+		return
+	}
+
+	msg := fmt.Sprintf("%v\n%v\n", time.Now().Format(time.RFC3339), cause)
+	if err := ioutil.WriteFile(dst+".error", []byte(msg), 0644); err != nil {
+		log.Printf("hot folder can't write error of %v: %v", dst, err)
+	}
+}
+
+// Rename the file or copy it if the directories are on different devices
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,236 @@
+package hotFolder
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+func Test_Poll(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	modTime := time.Date(2016, time.May, 1, 10, 0, 0, 0, time.UTC)
+	writeScan(t, w.Dir, "scan.pdf", modTime)
+	writeScan(t, w.Dir, "notes.txt", modTime)
+
+	// Seen for the first time
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(w.Dir, "scan.pdf")); err != nil {
+		t.Fatal("Expect scan.pdf to wait")
+	}
+
+	// Unchanged since the last poll
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(w.Files, "scan.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(w.Dir, "notes.txt")); err != nil {
+		t.Fatal("Expect notes.txt to be ignored")
+	}
+
+	doc := docs.Doc{}
+	if err := db.SelectOne(&doc, "SELECT * FROM docs WHERE name=?", "scan.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.DateOfScan.Equal(modTime) {
+		t.Fatalf("Expect %v was %v", modTime, doc.DateOfScan)
+	}
+	if doc.Barcode != "" {
+		t.Fatalf("Expect no barcode was %v", doc.Barcode)
+	}
+
+	l, err := docs.FindLabelsOfDoc(db, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Name != "Eingang" {
+		t.Fatalf("Expect label Eingang was %v", l)
+	}
+}
+
+func Test_Poll_FileChanged(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	writeScan(t, w.Dir, "scan.pdf", time.Now().Add(-time.Minute))
+	w.Poll()
+
+	// Still written by the scanner
+	writeScan(t, w.Dir, "scan.pdf", time.Now())
+	w.Poll()
+
+	if _, err := os.Stat(path.Join(w.Dir, "scan.pdf")); err != nil {
+		t.Fatal("Expect changed scan.pdf to wait")
+	}
+}
+
+func Test_Poll_DeadLetter(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	w.Files = path.Join(w.Files, "missing")
+
+	writeScan(t, w.Dir, "scan.pdf", time.Now().Add(-time.Minute))
+	for i := 0; i < 3; i++ {
+		w.Poll()
+	}
+
+	if _, err := os.Stat(path.Join(w.DeadLetter, "scan.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(w.DeadLetter, "scan.pdf.error")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Poll_UnknownBarcode(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	// E.g. the barcode of a parcel service on an invoice
+	p := path.Join(w.Dir, "scan.pdf")
+	err := ioutil.WriteFile(p, []byte("%PDF-1.4\n(docma-barcode:X12) Tj\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w.Poll()
+	}
+
+	doc := docs.Doc{}
+	if err := db.SelectOne(&doc, "SELECT * FROM docs WHERE name=?", "scan.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Barcode != "" {
+		t.Fatalf("Expect no barcode was %v", doc.Barcode)
+	}
+	if _, err := os.Stat(path.Join(w.Files, "scan.pdf")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Poll_UsedBarcode(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	doc := docs.Doc{Name: "invoice.pdf", Barcode: "X12"}
+	if err := db.Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	p := path.Join(w.Dir, "scan.pdf")
+	err := ioutil.WriteFile(p, []byte("%PDF-1.4\n(docma-barcode:X12) Tj\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w.Poll()
+	}
+
+	n, err := db.SelectInt("SELECT COUNT(*) FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Expect 1 doc was %v", n)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(w.DeadLetter, "scan.pdf.error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "X12") {
+		t.Fatalf("Expect barcode X12 in %q", b)
+	}
+}
+
+func Test_StartStop(t *testing.T) {
+	db := initDB(t)
+	w, cleanup := newTestWatcher(t, db)
+	defer cleanup()
+
+	w.Interval = 10 * time.Millisecond
+	w.Start()
+
+	writeScan(t, w.Dir, "scan.pdf", time.Now().Add(-time.Minute))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path.Join(w.Files, "scan.pdf")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expect scan.pdf to be imported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.Stop()
+	w.Stop()
+}
+
+func newTestWatcher(t *testing.T, db *gorp.DbMap) (*Watcher, func()) {
+	root, err := ioutil.TempDir("", "hotFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := common.Specs{
+		HotFolder:       path.Join(root, "in"),
+		Files:           path.Join(root, "files"),
+		DeadLetter:      path.Join(root, "dead"),
+		HotFolderLabels: " Eingang, ",
+	}
+	for _, dir := range []string{specs.HotFolder, specs.Files, specs.DeadLetter} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := NewWatcher(db, specs)
+	w.StablePolls = 1
+
+	return w, func() { os.RemoveAll(root) }
+}
+
+func writeScan(t *testing.T, dir, name string, modTime time.Time) {
+	p := path.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte("%PDF-1.4 "+modTime.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func initDB(t *testing.T) *gorp.DbMap {
+	return common.InitTestDB(t, docs.AddTables, labels.AddTables, dbVars.AddTables)
+}
@@ -1,13 +1,37 @@
 package labels
 
-import "gopkg.in/gorp.v1"
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/gorp.v1"
+)
 
 var (
-	LabelsTable = "labels"
+	LabelsTable      = "labels"
+	LabelGroupsTable = "label_groups"
+	// The table is mapped by the docs package
+	DocsLabelsTable = "docs_labels"
+
+	ErrMergeSameLabel = errors.New("cannot merge a label into itself")
 )
 
+// Returned if a label which is attached to docs should be deleted
+type LabelInUseError struct {
+	DocCount int64
+}
+
+func (e LabelInUseError) Error() string {
+	return fmt.Sprintf("label is attached to %v docs", e.DocCount)
+}
+
 func AddTables(db *gorp.DbMap) {
-	db.AddTableWithName(Label{}, LabelsTable).
+	tMap := db.AddTableWithName(Label{}, LabelsTable).
+		SetKeys(true, "id")
+	tMap.ColMap("name").SetUnique(true)
+	tMap.ColMap("description").SetMaxSize(1024)
+
+	db.AddTableWithName(LabelGroup{}, LabelGroupsTable).
 		SetKeys(true, "id").
 		ColMap("name").
 		SetUnique(true)
@@ -22,3 +46,155 @@ func CreateTables(db *gorp.DbMap) error {
 
 	return nil
 }
+
+// Read all labels with the number of docs they are attached to
+func ReadLabelsUsage(db gorp.SqlExecutor, order string) ([]LabelUsage, error) {
+	q := Q(`
+	SELECT labels.*, COUNT(docs_labels.doc_id) AS doc_count
+	FROM %v as labels
+	LEFT JOIN %v as docs_labels ON docs_labels.label_id = labels.id
+	GROUP BY labels.id
+	%v`, LabelsTable, DocsLabelsTable, order)
+
+	l := []LabelUsage{}
+	if _, err := db.Select(&l, q); err != nil {
+		return []LabelUsage{}, err
+	}
+
+	return l, nil
+}
+
+// Attach all docs of label from to label into and delete label from.
+// Returns the number of docs which were moved to label into.
+func MergeLabels(db *gorp.DbMap, fromID, intoID int64) (int64, error) {
+	if fromID == intoID {
+		return 0, ErrMergeSameLabel
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := mergeLabels(tx, fromID, intoID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+func mergeLabels(tx gorp.SqlExecutor, fromID, intoID int64) (int64, error) {
+	from := Label{}
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
+	if err := tx.SelectOne(&from, q, fromID); err != nil {
+		return 0, err
+	}
+
+	into := Label{}
+	if err := tx.SelectOne(&into, q, intoID); err != nil {
+		return 0, err
+	}
+
+	// Docs which already have label into are skipped by IGNORE
+	// and their connections to label from are removed afterwards
+	q = Q("UPDATE IGNORE %v SET label_id=? WHERE label_id=?", DocsLabelsTable)
+	r, err := tx.Exec(q, intoID, fromID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	q = Q("DELETE FROM %v WHERE label_id=?", DocsLabelsTable)
+	if _, err := tx.Exec(q, fromID); err != nil {
+		return 0, err
+	}
+
+	// If label into is below label from move it up first
+	// otherwise the children of label from would create a cycle
+	descendants, err := DescendantIDs(tx, fromID)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range descendants {
+		if id == intoID {
+			q = Q("UPDATE %v SET parent_id=? WHERE id=?", LabelsTable)
+			if _, err := tx.Exec(q, from.ParentID, intoID); err != nil {
+				return 0, err
+			}
+			break
+		}
+	}
+
+	q = Q("UPDATE %v SET parent_id=? WHERE parent_id=?", LabelsTable)
+	if _, err := tx.Exec(q, intoID, fromID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Delete(&from); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+// Delete a label, if the label is attached to docs a LabelInUseError is
+// returned unless detach is set, then the label is removed from all docs.
+// Children of the label are moved to its parent.
+func DeleteLabelByID(db *gorp.DbMap, labelID int64, detach bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := deleteLabel(tx, labelID, detach); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteLabel(tx gorp.SqlExecutor, labelID int64, detach bool) error {
+	label := Label{}
+	q := Q("SELECT * FROM %v WHERE id=? FOR UPDATE", LabelsTable)
+	if err := tx.SelectOne(&label, q, labelID); err != nil {
+		return err
+	}
+
+	q = Q("SELECT COUNT(*) FROM %v WHERE label_id=?", DocsLabelsTable)
+	n, err := tx.SelectInt(q, labelID)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		if !detach {
+			return LabelInUseError{DocCount: n}
+		}
+
+		q = Q("DELETE FROM %v WHERE label_id=?", DocsLabelsTable)
+		if _, err := tx.Exec(q, labelID); err != nil {
+			return err
+		}
+	}
+
+	q = Q("UPDATE %v SET parent_id=? WHERE parent_id=?", LabelsTable)
+	if _, err := tx.Exec(q, label.ParentID, label.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Delete(&label); err != nil {
+		return err
+	}
+
+	return nil
+}
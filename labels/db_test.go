@@ -0,0 +1,147 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/gorp.v1"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/gin-gum/gumtest"
+)
+
+// The docs package owns the docs_labels table but cannot be imported here
+type testDocLabel struct {
+	DocID   int64 `db:"doc_id"`
+	LabelID int64 `db:"label_id"`
+}
+
+func Test_ReadLabelsUsage(t *testing.T) {
+	db := initDBWithDocs(t)
+	labels := fillTestDB(t, db)
+
+	err := db.Insert(
+		&testDocLabel{DocID: 1, LabelID: 2},
+		&testDocLabel{DocID: 2, LabelID: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadLabelsUsage(db, "ORDER BY doc_count DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []LabelUsage{
+		{Label: *labels[1], DocCount: 2},
+		{Label: *labels[0], DocCount: 0},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_MergeLabels(t *testing.T) {
+	db := initDBWithDocs(t)
+
+	labels := []*Label{
+		{ID: 1, Name: "Miete"},
+		{ID: 2, Name: "Mite"},
+		{ID: 3, Name: "Nebenkosten", ParentID: 2},
+	}
+	if err := db.Insert(gumtest.IfaceSlice(labels)...); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Insert(
+		&testDocLabel{DocID: 1, LabelID: 1},
+		&testDocLabel{DocID: 1, LabelID: 2},
+		&testDocLabel{DocID: 2, LabelID: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := MergeLabels(db, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved != 1 {
+		t.Fatalf("Expect %v was %v", 1, moved)
+	}
+
+	docsLabels := []testDocLabel{}
+	q := Q("SELECT * FROM %v ORDER BY doc_id", DocsLabelsTable)
+	if _, err := db.Select(&docsLabels, q); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []testDocLabel{
+		{DocID: 1, LabelID: 1},
+		{DocID: 2, LabelID: 1},
+	}
+	if !reflect.DeepEqual(expect, docsLabels) {
+		t.Fatalf("Expect %v was %v", expect, docsLabels)
+	}
+
+	result := []Label{}
+	if _, err := db.Select(&result, Q("SELECT * FROM %v ORDER BY id", LabelsTable)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectLabels := []Label{
+		{ID: 1, Name: "Miete"},
+		{ID: 3, Name: "Nebenkosten", ParentID: 1},
+	}
+	if !reflect.DeepEqual(expectLabels, result) {
+		t.Fatalf("Expect %v was %v", expectLabels, result)
+	}
+}
+
+func Test_MergeLabels_IntoChild(t *testing.T) {
+	db := initDBWithDocs(t)
+
+	labels := []*Label{
+		{ID: 1, Name: "Finanzen"},
+		{ID: 2, Name: "Bank", ParentID: 1},
+		{ID: 3, Name: "Sparkasse", ParentID: 2},
+	}
+	if err := db.Insert(gumtest.IfaceSlice(labels)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MergeLabels(db, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	result := []Label{}
+	if _, err := db.Select(&result, Q("SELECT * FROM %v ORDER BY id", LabelsTable)); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Label{
+		{ID: 1, Name: "Finanzen"},
+		{ID: 3, Name: "Sparkasse", ParentID: 1},
+	}
+	if !reflect.DeepEqual(expect, result) {
+		t.Fatalf("Expect %v was %v", expect, result)
+	}
+}
+
+func Test_MergeLabels_SameLabel(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillTestDB(t, db)
+
+	if _, err := MergeLabels(db, 1, 1); err != ErrMergeSameLabel {
+		t.Fatalf("Expect %v was %v", ErrMergeSameLabel, err)
+	}
+}
+
+func initDBWithDocs(t *testing.T) *gorp.DbMap {
+	return common.InitTestDB(t, AddTables, func(db *gorp.DbMap) {
+		db.AddTableWithName(testDocLabel{}, DocsLabelsTable).
+			SetKeys(false, "doc_id", "label_id")
+	})
+}
@@ -0,0 +1,120 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrGroupNotFound = errors.New("label group not found")
+)
+
+// Returned if a doc would carry two labels of an exclusive group
+type GroupConflictError struct {
+	Group   string
+	LabelID int64
+}
+
+func (e GroupConflictError) Error() string {
+	return fmt.Sprintf("doc already has label %v of exclusive group %v", e.LabelID, e.Group)
+}
+
+type groupConflict struct {
+	Group   string `db:"group_name"`
+	LabelID int64  `db:"label_id"`
+}
+
+// Check that a label can be attached to a doc without breaking the
+// exclusivity of the label's group
+func CheckExclusiveGroup(db gorp.SqlExecutor, docID, labelID int64) error {
+	q := Q(`
+	SELECT label_groups.name AS group_name, other.id AS label_id
+	FROM %v as label, %v as label_groups, %v as other, %v as docs_labels
+	WHERE label.id = ?
+	AND label_groups.id = label.group_id
+	AND label_groups.exclusive = true
+	AND other.group_id = label.group_id
+	AND other.id != label.id
+	AND docs_labels.label_id = other.id
+	AND docs_labels.doc_id = ?
+	LIMIT 1`, LabelsTable, LabelGroupsTable, LabelsTable, DocsLabelsTable)
+
+	l := []groupConflict{}
+	if _, err := db.Select(&l, q, labelID, docID); err != nil {
+		return err
+	}
+
+	if len(l) > 0 {
+		return GroupConflictError{
+			Group:   l[0].Group,
+			LabelID: l[0].LabelID,
+		}
+	}
+
+	return nil
+}
+
+// Check that the label can be moved into the group without a doc carrying
+// two labels of the group
+func checkGroupChange(db gorp.SqlExecutor, labelID, groupID int64) error {
+	if groupID == 0 {
+		return nil
+	}
+
+	group := LabelGroup{}
+	q := Q("SELECT * FROM %v WHERE id=?", LabelGroupsTable)
+	if err := db.SelectOne(&group, q, groupID); err != nil {
+		return ErrGroupNotFound
+	}
+
+	if !group.Exclusive || labelID == 0 {
+		return nil
+	}
+
+	q = Q(`
+	SELECT other.id AS label_id, ? AS group_name
+	FROM %v as other, %v as docs_labels, %v as own
+	WHERE other.group_id = ?
+	AND other.id != ?
+	AND docs_labels.label_id = other.id
+	AND own.doc_id = docs_labels.doc_id
+	AND own.label_id = ?
+	LIMIT 1`, LabelsTable, DocsLabelsTable, DocsLabelsTable)
+
+	l := []groupConflict{}
+	if _, err := db.Select(&l, q, group.Name, groupID, labelID, labelID); err != nil {
+		return err
+	}
+
+	if len(l) > 0 {
+		return GroupConflictError{
+			Group:   l[0].Group,
+			LabelID: l[0].LabelID,
+		}
+	}
+
+	return nil
+}
+
+// Delete a group, the labels of the group are kept without group
+func DeleteLabelGroupByID(db *gorp.DbMap, groupID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	q := Q("UPDATE %v SET group_id=0 WHERE group_id=?", LabelsTable)
+	if _, err := tx.Exec(q, groupID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Delete(&LabelGroup{ID: groupID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,131 @@
+package labels
+
+import (
+	"net/http"
+	"testing"
+
+	"gopkg.in/gorp.v1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+)
+
+func Test_CheckExclusiveGroup(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillGroupTestDB(t, db)
+
+	if err := db.Insert(&testDocLabel{DocID: 1, LabelID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CheckExclusiveGroup(db, 1, 2)
+	expect := GroupConflictError{Group: "Type", LabelID: 1}
+	if err != expect {
+		t.Fatalf("Expect %v was %v", expect, err)
+	}
+
+	// Label of a not exclusive group
+	if err := CheckExclusiveGroup(db, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other doc
+	if err := CheckExclusiveGroup(db, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_UpdateLabel(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillGroupTestDB(t, db)
+
+	r := gin.New()
+	r.PUT("/:labelID", gumwrap.Gorp(UpdateLabel, db))
+
+	body := `{"name": "Rechnung", "color": "#ff0000", "description": "Eingangsrechnung", "icon": "receipt", "group_id": 1}`
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/1", body)
+
+	expect := Label{
+		ID:          1,
+		Name:        "Rechnung",
+		Color:       "#ff0000",
+		Description: "Eingangsrechnung",
+		Icon:        "receipt",
+		GroupID:     1,
+	}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_UpdateLabel_GroupConflict(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillGroupTestDB(t, db)
+
+	err := db.Insert(
+		&testDocLabel{DocID: 1, LabelID: 1},
+		&testDocLabel{DocID: 1, LabelID: 3},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PUT("/:labelID", gumwrap.Gorp(UpdateLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/3", `{"name": "2016", "group_id": 1}`)
+
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("Expect %v was %v", http.StatusConflict, resp.Code)
+	}
+}
+
+func Test_CreateLabel_InvalidColor(t *testing.T) {
+	db := initDB(t)
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", `{"name": "daemon", "color": "red"}`)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func Test_DeleteLabelGroup(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillGroupTestDB(t, db)
+
+	r := gin.New()
+	r.DELETE("/:groupID", gumwrap.Gorp(DeleteLabelGroup, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("DELETE", "/1", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+	}
+
+	n, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v WHERE group_id=1", LabelsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("Expect %v was %v", 0, n)
+	}
+}
+
+func fillGroupTestDB(t *testing.T, db *gorp.DbMap) {
+	err := db.Insert(
+		&LabelGroup{ID: 1, Name: "Type", Exclusive: true},
+		&LabelGroup{ID: 2, Name: "Year"},
+		&Label{ID: 1, Name: "Invoice", GroupID: 1},
+		&Label{ID: 2, Name: "Contract", GroupID: 1},
+		&Label{ID: 3, Name: "2016", GroupID: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
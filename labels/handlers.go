@@ -1,18 +1,48 @@
 package labels
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/valid"
 	"github.com/tochti/gin-gum/gumrest"
 	"gopkg.in/gorp.v1"
 )
 
 var (
 	ErrNameMissing = errors.New("label name is missing")
+
+	LabelSortFields = common.SortFields{
+		"id":   "id",
+		"name": "name",
+	}
+
+	LabelUsageSortFields = common.SortFields{
+		"id":        "labels.id",
+		"name":      "labels.name",
+		"doc_count": "doc_count",
+	}
+)
+
+type (
+	MergeForm struct {
+		Into int64 `json:"into"`
+	}
+
+	DeleteConflict struct {
+		Message  string `json:"message"`
+		DocCount int64  `json:"doc_count"`
+	}
+
+	MergeResult struct {
+		Label     Label `json:"label"`
+		MovedDocs int64 `json:"moved_docs"`
+	}
 )
 
 func CreateLabel(c *gin.Context, db *gorp.DbMap) {
@@ -37,6 +67,35 @@ func CreateLabel(c *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	if err := valid.Struct(label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if label.ParentID != 0 {
+		if err := checkParent(db, label.ParentID); err != nil {
+			gumrest.ErrorResponse(
+				c,
+				http.StatusBadRequest,
+				err,
+			)
+			return
+		}
+	}
+
+	if err := checkGroupChange(db, 0, label.GroupID); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
 	err = db.Insert(label)
 	if err != nil {
 		gumrest.ErrorResponse(
@@ -51,19 +110,50 @@ func CreateLabel(c *gin.Context, db *gorp.DbMap) {
 }
 
 func ReadAllLabels(c *gin.Context, db *gorp.DbMap) {
+	page, err := common.ReadPage(c)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	order, err := page.SQL(LabelSortFields, "id")
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
 	labels := []Label{}
 
-	q := Q("SELECT id, name FROM %v", LabelsTable)
+	q := Q("SELECT * FROM %v", LabelsTable)
+	count := Q("SELECT COUNT(*) FROM %v", LabelsTable)
+	params := []interface{}{}
 
 	name := c.Query("name")
-	var err error
 	if name != "" {
 		q = fmt.Sprintf("%v WHERE name=?", q)
-		_, err = db.Select(&labels, q, name)
-	} else {
-		_, err = db.Select(&labels, q)
+		count = fmt.Sprintf("%v WHERE name=?", count)
+		params = append(params, name)
+	}
+
+	_, err = db.Select(&labels, fmt.Sprintf("%v %v", q, order), params...)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
 	}
 
+	total, err := db.SelectInt(count, params...)
 	if err != nil {
 		gumrest.ErrorResponse(
 			c,
@@ -73,9 +163,86 @@ func ReadAllLabels(c *gin.Context, db *gorp.DbMap) {
 		return
 	}
 
+	common.SetTotalCount(c, total)
 	c.JSON(http.StatusOK, labels)
 }
 
+// List all labels with the number of docs they are attached to
+func ReadAllLabelsUsage(c *gin.Context, db *gorp.DbMap) {
+	page, err := common.ReadPage(c)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	order, err := page.SQL(LabelUsageSortFields, "labels.id")
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	l, err := ReadLabelsUsage(db, order)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	total, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v", LabelsTable))
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	common.SetTotalCount(c, total)
+	c.JSON(http.StatusOK, l)
+}
+
+// Suggest labels for the query parameter q, ranked by match and usage.
+// The number of suggestions is set by the query parameter limit.
+func SuggestLabels(c *gin.Context, db *gorp.DbMap) {
+	limit := DefaultSuggestLimit
+	if tmp := c.Query("limit"); tmp != "" {
+		i, err := strconv.Atoi(tmp)
+		if err != nil || i < 0 {
+			gumrest.ErrorResponse(
+				c,
+				http.StatusBadRequest,
+				common.ErrInvalidLimit,
+			)
+			return
+		}
+		limit = i
+	}
+
+	l, err := ReadLabelsUsage(db, "")
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, RankLabels(l, c.Query("q"), limit))
+}
+
 func ReadOneLabel(c *gin.Context, db *gorp.DbMap) {
 	labelID, err := ReadLabelID(c)
 	if err != nil {
@@ -83,7 +250,7 @@ func ReadOneLabel(c *gin.Context, db *gorp.DbMap) {
 	}
 
 	label := Label{}
-	q := Q("SELECT id, name FROM %v WHERE id=?", LabelsTable)
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
 	err = db.SelectOne(&label, q, labelID)
 	if err != nil {
 		gumrest.ErrorResponse(
@@ -97,14 +264,318 @@ func ReadOneLabel(c *gin.Context, db *gorp.DbMap) {
 	c.JSON(http.StatusOK, label)
 }
 
+// Delete a label. If the label is still attached to docs the request is
+// refused with 409 unless the query parameter detach is true.
 func DeleteLabel(c *gin.Context, db *gorp.DbMap) {
 	labelID, err := ReadLabelID(c)
 	if err != nil {
 		return
 	}
 
-	_, err = db.Delete(&Label{ID: labelID})
+	detach := c.Query("detach") == "true"
+	err = DeleteLabelByID(db, labelID, detach)
+	switch e := err.(type) {
+	case nil:
+		c.JSON(http.StatusOK, nil)
+	case LabelInUseError:
+		c.JSON(http.StatusConflict, DeleteConflict{
+			Message:  e.Error(),
+			DocCount: e.DocCount,
+		})
+	default:
+		if err == sql.ErrNoRows {
+			gumrest.ErrorResponse(
+				c,
+				http.StatusNotFound,
+				err,
+			)
+			return
+		}
+
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+	}
+}
+
+// Rename a label, expects {"name": "Miete"}
+func RenameLabel(c *gin.Context, db *gorp.DbMap) {
+	labelID, err := ReadLabelID(c)
+	if err != nil {
+		return
+	}
+
+	label := Label{}
+	if err := c.BindJSON(&label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if label.Name == "" {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			ErrNameMissing,
+		)
+		return
+	}
+
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
+	tmp := Label{}
+	if err := db.SelectOne(&tmp, q, labelID); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+
+	// If the name is already taken the labels should be merged instead
+	tmp.Name = label.Name
+	if _, err := db.Update(&tmp); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmp)
+}
+
+// Update name, color, description, icon and group of a label. The parent is
+// changed with MoveLabel.
+func UpdateLabel(c *gin.Context, db *gorp.DbMap) {
+	labelID, err := ReadLabelID(c)
 	if err != nil {
+		return
+	}
+
+	label := Label{}
+	if err := c.BindJSON(&label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if label.Name == "" {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			ErrNameMissing,
+		)
+		return
+	}
+
+	if err := valid.Struct(label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	tmp := Label{}
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
+	if err := db.SelectOne(&tmp, q, labelID); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+
+	if label.GroupID != tmp.GroupID {
+		err := checkGroupChange(db, labelID, label.GroupID)
+		if _, ok := err.(GroupConflictError); ok {
+			gumrest.ErrorResponse(
+				c,
+				http.StatusConflict,
+				err,
+			)
+			return
+		}
+		if err != nil {
+			gumrest.ErrorResponse(
+				c,
+				http.StatusBadRequest,
+				err,
+			)
+			return
+		}
+	}
+
+	label.ID = labelID
+	label.ParentID = tmp.ParentID
+	if _, err := db.Update(&label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// Merge a label into another, expects {"into": 2}
+func MergeLabel(c *gin.Context, db *gorp.DbMap) {
+	labelID, err := ReadLabelID(c)
+	if err != nil {
+		return
+	}
+
+	form := MergeForm{}
+	if err := c.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	moved, err := MergeLabels(db, labelID, form.Into)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	label := Label{}
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
+	if err := db.SelectOne(&label, q, form.Into); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, MergeResult{
+		Label:     label,
+		MovedDocs: moved,
+	})
+}
+
+// Move a label in the tree, expects {"parent_id": 1}
+func MoveLabel(c *gin.Context, db *gorp.DbMap) {
+	labelID, err := ReadLabelID(c)
+	if err != nil {
+		return
+	}
+
+	label := Label{}
+	if err := c.BindJSON(&label); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := SetLabelParent(db, labelID, label.ParentID); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	q := Q("SELECT * FROM %v WHERE id=?", LabelsTable)
+	if err := db.SelectOne(&label, q, labelID); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+func CreateLabelGroup(c *gin.Context, db *gorp.DbMap) {
+	group := LabelGroup{}
+	if err := c.BindJSON(&group); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := valid.Struct(group); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := db.Insert(&group); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+func ReadAllLabelGroups(c *gin.Context, db *gorp.DbMap) {
+	groups := []LabelGroup{}
+	q := Q("SELECT * FROM %v ORDER BY name", LabelGroupsTable)
+	if _, err := db.Select(&groups, q); err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+
+	common.SetTotalCount(c, int64(len(groups)))
+	c.JSON(http.StatusOK, groups)
+}
+
+func DeleteLabelGroup(c *gin.Context, db *gorp.DbMap) {
+	tmp := c.Params.ByName("groupID")
+	groupID, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil {
+		gumrest.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := DeleteLabelGroupByID(db, groupID); err != nil {
 		gumrest.ErrorResponse(
 			c,
 			http.StatusBadRequest,
@@ -77,6 +77,40 @@ func Test_ReadAllLabelsWithFilter(t *testing.T) {
 	}
 }
 
+func Test_ReadAllLabelsPage(t *testing.T) {
+	db := initDB(t)
+	labels := fillTestDB(t, db)
+
+	r := gin.New()
+	r.GET("/", gumwrap.Gorp(ReadAllLabels, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/?sort=name&order=desc&limit=1", "")
+
+	expectResp := gumtest.JSONResponse{http.StatusOK, []*Label{labels[1]}}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if total := resp.Header().Get(common.TotalCountHeader); total != "2" {
+		t.Fatalf("Expect %v was %v", "2", total)
+	}
+}
+
+func Test_ReadAllLabelsPage_UnknownSortField(t *testing.T) {
+	db := initDB(t)
+	fillTestDB(t, db)
+
+	r := gin.New()
+	r.GET("/", gumwrap.Gorp(ReadAllLabels, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/?sort=color", "")
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
 func Test_ReadOneLabel(t *testing.T) {
 	db := initDB(t)
 	labels := fillTestDB(t, db)
@@ -109,7 +143,7 @@ func Test_ReadOneLabelFail(t *testing.T) {
 }
 
 func Test_DeleteLabel(t *testing.T) {
-	db := initDB(t)
+	db := initDBWithDocs(t)
 	fillTestDB(t, db)
 
 	r := gin.New()
@@ -124,10 +158,143 @@ func Test_DeleteLabel(t *testing.T) {
 	}
 }
 
+func Test_DeleteLabel_InUse(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillTestDB(t, db)
+
+	err := db.Insert(
+		&testDocLabel{DocID: 1, LabelID: 1},
+		&testDocLabel{DocID: 2, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.DELETE("/:labelID", gumwrap.Gorp(DeleteLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("DELETE", "/1", "")
+
+	expect := DeleteConflict{
+		Message:  "label is attached to 2 docs",
+		DocCount: 2,
+	}
+	expectResp := gumtest.JSONResponse{http.StatusConflict, expect}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_DeleteLabel_Detach(t *testing.T) {
+	db := initDBWithDocs(t)
+	fillTestDB(t, db)
+
+	err := db.Insert(
+		&testDocLabel{DocID: 1, LabelID: 1},
+		&testDocLabel{DocID: 1, LabelID: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.DELETE("/:labelID", gumwrap.Gorp(DeleteLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("DELETE", "/1?detach=true", "")
+
+	expectResp := gumtest.JSONResponse{http.StatusOK, nil}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v", DocsLabelsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("Expect %v was %v", 1, n)
+	}
+}
+
+func Test_RenameLabel(t *testing.T) {
+	db := initDB(t)
+	fillTestDB(t, db)
+
+	r := gin.New()
+	r.PUT("/:labelID", gumwrap.Gorp(RenameLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/1", `{"name": "worse"}`)
+
+	expectResp := gumtest.JSONResponse{http.StatusOK, Label{ID: 1, Name: "worse"}}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_RenameLabel_NameTaken(t *testing.T) {
+	db := initDB(t)
+	fillTestDB(t, db)
+
+	r := gin.New()
+	r.PUT("/:labelID", gumwrap.Gorp(RenameLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/1", `{"name": "good"}`)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func Test_ReadAllLabelsUsage(t *testing.T) {
+	db := initDBWithDocs(t)
+	labels := fillTestDB(t, db)
+
+	if err := db.Insert(&testDocLabel{DocID: 1, LabelID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/", gumwrap.Gorp(ReadAllLabelsUsage, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/?sort=doc_count&order=desc", "")
+
+	expect := []LabelUsage{
+		{Label: *labels[0], DocCount: 1},
+		{Label: *labels[1], DocCount: 0},
+	}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SuggestLabels(t *testing.T) {
+	db := initDBWithDocs(t)
+	labels := fillTestDB(t, db)
+
+	r := gin.New()
+	r.GET("/", gumwrap.Gorp(SuggestLabels, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("GET", "/?q=Goo", "")
+
+	expect := []Suggestion{
+		{LabelUsage: LabelUsage{Label: *labels[1]}, Match: MatchPrefix},
+	}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func fillTestDB(t *testing.T, db *gorp.DbMap) []*Label {
 	labels := []*Label{
-		{1, "bad"},
-		{2, "good"},
+		{ID: 1, Name: "bad"},
+		{ID: 2, Name: "good"},
 	}
 
 	db.Insert(gumtest.IfaceSlice(labels)...)
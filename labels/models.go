@@ -1,8 +1,28 @@
 package labels
 
 type (
+	// Labels form a tree, a label without parent has ParentID 0.
+	// A label without group has GroupID 0.
 	Label struct {
-		ID   int64  `db:"id" json:"id"`
-		Name string `db:"name" json:"name"`
+		ID          int64  `db:"id" json:"id"`
+		Name        string `db:"name" json:"name"`
+		ParentID    int64  `db:"parent_id" json:"parent_id"`
+		Color       string `db:"color" json:"color" valid:"omitempty,hexcolor"`
+		Description string `db:"description" json:"description"`
+		Icon        string `db:"icon" json:"icon" valid:"max=64"`
+		GroupID     int64  `db:"group_id" json:"group_id"`
+	}
+
+	LabelUsage struct {
+		Label
+		DocCount int64 `db:"doc_count" json:"doc_count"`
+	}
+
+	// Groups like "Type", "Year" or "Counterparty". A doc may carry at
+	// most one label of an exclusive group.
+	LabelGroup struct {
+		ID        int64  `db:"id" json:"id"`
+		Name      string `db:"name" json:"name" valid:"required"`
+		Exclusive bool   `db:"exclusive" json:"exclusive"`
 	}
 )
@@ -0,0 +1,199 @@
+package labels
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	// Number of suggestions if no limit is given
+	DefaultSuggestLimit = 10
+
+	umlauts = strings.NewReplacer(
+		"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+		"á", "a", "à", "a", "â", "a",
+		"é", "e", "è", "e", "ê", "e",
+		"í", "i", "ì", "i", "î", "i",
+		"ó", "o", "ò", "o", "ô", "o",
+		"ú", "u", "ù", "u", "û", "u",
+		"ç", "c", "ñ", "n",
+	)
+)
+
+// How a suggestion matched the query, better matches have lower values
+const (
+	MatchExact = iota
+	MatchPrefix
+	MatchWordPrefix
+	MatchSubstring
+	MatchFuzzy
+)
+
+type Suggestion struct {
+	LabelUsage
+	Match    int `json:"match"`
+	Distance int `json:"distance"`
+}
+
+// Normalize a label name for comparison. The result is lower case, umlauts
+// are written as ae, oe, ue and ss and whitespace is collapsed.
+func Normalize(s string) string {
+	s = umlauts.Replace(strings.ToLower(s))
+	return strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+}
+
+// Rank labels by how well they match the query. Labels matching equally
+// well are ordered by the number of docs they are attached to.
+func RankLabels(labels []LabelUsage, query string, limit int) []Suggestion {
+	q := Normalize(query)
+	r := []Suggestion{}
+	if q == "" {
+		return r
+	}
+
+	maxDist := maxDistance(q)
+	for _, l := range labels {
+		name := Normalize(l.Name)
+		s := Suggestion{LabelUsage: l}
+
+		switch {
+		case name == q:
+			s.Match = MatchExact
+		case strings.HasPrefix(name, q):
+			s.Match = MatchPrefix
+		case hasWordPrefix(name, q):
+			s.Match = MatchWordPrefix
+		case strings.Contains(name, q):
+			s.Match = MatchSubstring
+		default:
+			d := fuzzyDistance(name, q)
+			if d > maxDist {
+				continue
+			}
+			s.Match = MatchFuzzy
+			s.Distance = d
+		}
+
+		r = append(r, s)
+	}
+
+	sort.Sort(byRank(r))
+
+	if limit > 0 && len(r) > limit {
+		r = r[:limit]
+	}
+
+	return r
+}
+
+type byRank []Suggestion
+
+func (r byRank) Len() int      { return len(r) }
+func (r byRank) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byRank) Less(i, j int) bool {
+	a, b := r[i], r[j]
+	if a.Match != b.Match {
+		return a.Match < b.Match
+	}
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+	if a.DocCount != b.DocCount {
+		return a.DocCount > b.DocCount
+	}
+	return a.Name < b.Name
+}
+
+// Allow more typos in longer queries
+func maxDistance(q string) int {
+	n := len([]rune(q))
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func hasWordPrefix(name, q string) bool {
+	for _, w := range strings.FieldsFunc(name, isSeparator) {
+		if strings.HasPrefix(w, q) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// Distance of the query to the whole name, to one of its words or to the
+// start of them while the user is still typing
+func fuzzyDistance(name, q string) int {
+	d := distance(name, q)
+	l := len([]rune(q))
+
+	candidates := append([]string{name}, strings.FieldsFunc(name, isSeparator)...)
+	for i, c := range candidates {
+		if i > 0 {
+			d = minInt(d, distance(c, q))
+		}
+
+		if r := []rune(c); len(r) > l {
+			d = minInt(d, distance(string(r[:l]), q))
+		}
+	}
+
+	return d
+}
+
+// Optimal string alignment distance, like the Levenshtein distance but
+// swapping two neighbouring characters counts as one edit
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(
+				d[i-1][j]+1,
+				d[i][j-1]+1,
+				d[i-1][j-1]+cost,
+			)
+
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+cost)
+			}
+		}
+	}
+
+	return d[len(s)][len(t)]
+}
+
+func minInt(v int, l ...int) int {
+	for _, e := range l {
+		if e < v {
+			v = e
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,91 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Normalize(t *testing.T) {
+	tests := map[string]string{
+		"Müller":           "mueller",
+		"  Straße  Nr. 1 ": "strasse nr. 1",
+		"CAFÉ":             "cafe",
+	}
+
+	for in, expect := range tests {
+		if r := Normalize(in); r != expect {
+			t.Fatalf("Expect %v was %v", expect, r)
+		}
+	}
+}
+
+func Test_Distance(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"miete", "miete", 0},
+		{"miete", "mite", 1},
+		{"miete", "meite", 1},
+		{"hosting", "hostnig", 1},
+		{"", "abc", 3},
+	}
+
+	for _, test := range tests {
+		if r := distance(test.a, test.b); r != test.expect {
+			t.Fatalf("Expect %v for %v, %v was %v", test.expect, test.a, test.b, r)
+		}
+	}
+}
+
+func Test_RankLabels(t *testing.T) {
+	labels := []LabelUsage{
+		{Label: Label{ID: 1, Name: "Mietvertrag"}, DocCount: 1},
+		{Label: Label{ID: 2, Name: "Miete"}, DocCount: 2},
+		{Label: Label{ID: 3, Name: "Nebenkosten Miete"}, DocCount: 5},
+		{Label: Label{ID: 4, Name: "Mieterhöhung"}, DocCount: 7},
+		{Label: Label{ID: 5, Name: "Strom"}, DocCount: 9},
+	}
+
+	r := RankLabels(labels, "Mie", 0)
+
+	ids := []int64{}
+	for _, s := range r {
+		ids = append(ids, s.ID)
+	}
+
+	expect := []int64{4, 2, 1, 3}
+	if !reflect.DeepEqual(expect, ids) {
+		t.Fatalf("Expect %v was %v", expect, ids)
+	}
+}
+
+func Test_RankLabels_Fuzzy(t *testing.T) {
+	labels := []LabelUsage{
+		{Label: Label{ID: 1, Name: "Müller GmbH"}, DocCount: 1},
+		{Label: Label{ID: 2, Name: "Miete"}, DocCount: 2},
+		{Label: Label{ID: 3, Name: "Strom"}, DocCount: 9},
+	}
+
+	r := RankLabels(labels, "mueler", 10)
+	if len(r) != 1 || r[0].ID != 1 || r[0].Match != MatchFuzzy {
+		t.Fatalf("Expect fuzzy match of label 1 was %v", r)
+	}
+
+	r = RankLabels(labels, "Meite", 10)
+	if len(r) != 1 || r[0].ID != 2 || r[0].Distance != 1 {
+		t.Fatalf("Expect fuzzy match of label 2 was %v", r)
+	}
+}
+
+func Test_RankLabels_Limit(t *testing.T) {
+	labels := []LabelUsage{
+		{Label: Label{ID: 1, Name: "Miete 2015"}},
+		{Label: Label{ID: 2, Name: "Miete 2016"}},
+	}
+
+	r := RankLabels(labels, "miete", 1)
+	if len(r) != 1 {
+		t.Fatalf("Expect %v was %v", 1, len(r))
+	}
+}
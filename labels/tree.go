@@ -0,0 +1,150 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrLabelCycle     = errors.New("label cannot be moved below itself")
+	ErrParentNotFound = errors.New("parent label not found")
+)
+
+type treeNode struct {
+	ID       int64 `db:"id"`
+	ParentID int64 `db:"parent_id"`
+}
+
+// Return the ids of the labels and of all their descendants
+func DescendantIDs(db gorp.SqlExecutor, labelIDs ...int64) ([]int64, error) {
+	nodes := []treeNode{}
+	q := Q("SELECT id, parent_id FROM %v", LabelsTable)
+	if _, err := db.Select(&nodes, q); err != nil {
+		return []int64{}, err
+	}
+
+	children := map[int64][]int64{}
+	for _, n := range nodes {
+		children[n.ParentID] = append(children[n.ParentID], n.ID)
+	}
+
+	seen := map[int64]bool{}
+	r := []int64{}
+	queue := append([]int64{}, labelIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		r = append(r, id)
+		queue = append(queue, children[id]...)
+	}
+
+	return r, nil
+}
+
+// Find labels by name together with all their descendants
+func FindLabelsWithDescendants(db gorp.SqlExecutor, names []string) ([]Label, error) {
+	if len(names) == 0 {
+		return []Label{}, nil
+	}
+
+	all := []Label{}
+	if _, err := db.Select(&all, Q("SELECT * FROM %v", LabelsTable)); err != nil {
+		return []Label{}, err
+	}
+
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[n] = true
+	}
+
+	ids := []int64{}
+	for _, l := range all {
+		if wanted[l.Name] {
+			ids = append(ids, l.ID)
+		}
+	}
+
+	descendants, err := DescendantIDs(db, ids...)
+	if err != nil {
+		return []Label{}, err
+	}
+
+	in := map[int64]bool{}
+	for _, id := range descendants {
+		in[id] = true
+	}
+
+	r := []Label{}
+	for _, l := range all {
+		if in[l.ID] {
+			r = append(r, l)
+		}
+	}
+
+	return r, nil
+}
+
+// Set the parent of a label, a parent ID of 0 moves the label to the root
+func SetLabelParent(db gorp.SqlExecutor, labelID, parentID int64) error {
+	if parentID != 0 {
+		if err := checkParent(db, parentID); err != nil {
+			return err
+		}
+
+		descendants, err := DescendantIDs(db, labelID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range descendants {
+			if id == parentID {
+				return ErrLabelCycle
+			}
+		}
+	}
+
+	q := Q("UPDATE %v SET parent_id=? WHERE id=?", LabelsTable)
+	r, err := db.Exec(q, parentID, labelID)
+	if err != nil {
+		return err
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		// No rows are affected if the parent didn't change,
+		// so check that the label exists
+		c, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v WHERE id=?", LabelsTable), labelID)
+		if err != nil {
+			return err
+		}
+		if c == 0 {
+			return fmt.Errorf("label %v not found", labelID)
+		}
+	}
+
+	return nil
+}
+
+func checkParent(db gorp.SqlExecutor, parentID int64) error {
+	q := Q("SELECT COUNT(*) FROM %v WHERE id=?", LabelsTable)
+	n, err := db.SelectInt(q, parentID)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrParentNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package labels
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gopkg.in/gorp.v1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+)
+
+func Test_DescendantIDs(t *testing.T) {
+	db := initDB(t)
+	fillTreeTestDB(t, db)
+
+	r, err := DescendantIDs(db, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []int64{2, 3, 4}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_FindLabelsWithDescendants(t *testing.T) {
+	db := initDB(t)
+	labels := fillTreeTestDB(t, db)
+
+	r, err := FindLabelsWithDescendants(db, []string{"Bank"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Label{*labels[1], *labels[2], *labels[3]}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+}
+
+func Test_SetLabelParent_Cycle(t *testing.T) {
+	db := initDB(t)
+	fillTreeTestDB(t, db)
+
+	err := SetLabelParent(db, 2, 3)
+	if err != ErrLabelCycle {
+		t.Fatalf("Expect %v was %v", ErrLabelCycle, err)
+	}
+}
+
+func Test_MoveLabel(t *testing.T) {
+	db := initDB(t)
+	fillTreeTestDB(t, db)
+
+	r := gin.New()
+	r.PUT("/:labelID/parent", gumwrap.Gorp(MoveLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/5/parent", `{"parent_id": 2}`)
+
+	expect := Label{ID: 5, Name: "Miete", ParentID: 2}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_MoveLabel_UnknownParent(t *testing.T) {
+	db := initDB(t)
+	fillTreeTestDB(t, db)
+
+	r := gin.New()
+	r.PUT("/:labelID/parent", gumwrap.Gorp(MoveLabel, db))
+
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/5/parent", `{"parent_id": 42}`)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+// Finanzen/Bank/Sparkasse, Finanzen/Bank/Volksbank and Miete
+func fillTreeTestDB(t *testing.T, db *gorp.DbMap) []*Label {
+	labels := []*Label{
+		{ID: 1, Name: "Finanzen"},
+		{ID: 2, Name: "Bank", ParentID: 1},
+		{ID: 3, Name: "Sparkasse", ParentID: 2},
+		{ID: 4, Name: "Volksbank", ParentID: 2},
+		{ID: 5, Name: "Miete"},
+	}
+
+	if err := db.Insert(gumtest.IfaceSlice(labels)...); err != nil {
+		t.Fatal(err)
+	}
+
+	return labels
+}
@@ -0,0 +1,53 @@
+package mailImport
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+// Import the mails of the Maildir of the specs and of the IMAP mailbox if
+// one is configured
+func RunMailImportHandler(ginCtx *gin.Context, db *gorp.DbMap, specs common.Specs) {
+	i := Importer{DB: db, Files: specs.Files}
+	r := newResult()
+
+	if specs.Maildir != "" {
+		tmp, err := i.ImportMaildir(specs.Maildir)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+			return
+		}
+		r = mergeResults(r, tmp)
+	}
+
+	cfg, err := LoadIMAPConfig()
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
+	}
+	if cfg.Addr != "" {
+		tmp, err := i.ImportIMAP(cfg)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadGateway, err)
+			return
+		}
+		r = mergeResults(r, tmp)
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func mergeResults(a, b Result) Result {
+	a.Imported += b.Imported
+	a.Skipped += b.Skipped
+	a.DocIDs = append(a.DocIDs, b.DocIDs...)
+	for k, v := range b.Failed {
+		a.Failed[k] = v
+	}
+
+	return a
+}
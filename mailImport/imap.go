@@ -0,0 +1,254 @@
+package mailImport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/tochti/docMa-handler/common"
+)
+
+var (
+	// Timeout of every single command, a slow mailbox fails only if one
+	// command takes longer
+	IMAPTimeout = time.Minute
+
+	literalRe     = regexp.MustCompile(`\{(\d+)\}$`)
+	uidValidityRe = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+	existsRe      = regexp.MustCompile(`^\* (\d+) EXISTS`)
+	fetchUIDRe    = regexp.MustCompile(`\bUID (\d+)`)
+)
+
+type IMAPConfig struct {
+	// host:port, mails are not fetched if it is empty
+	Addr     string `envconfig:"ADDR"`
+	User     string `envconfig:"USER"`
+	Password string `envconfig:"PASSWORD"`
+	Mailbox  string `envconfig:"MAILBOX"`
+	TLS      bool   `envconfig:"TLS"`
+}
+
+// Read the config from DOCMA_IMAP_ADDR, DOCMA_IMAP_USER, ...
+func LoadIMAPConfig() (IMAPConfig, error) {
+	cfg := IMAPConfig{}
+	err := envconfig.Process(common.AppName+"_imap", &cfg)
+	return cfg, err
+}
+
+func (cfg IMAPConfig) mailbox() string {
+	if cfg.Mailbox == "" {
+		return "INBOX"
+	}
+	return cfg.Mailbox
+}
+
+// Minimal IMAP client which supports the commands needed to fetch mails.
+// BODY.PEEK is used so no flags change on the server.
+type IMAPClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	// UIDVALIDITY of the selected mailbox, UIDs of different sessions are
+	// only comparable if it is the same
+	UIDValidity int64
+	// Number of mails in the selected mailbox
+	Exists int
+}
+
+// Message-ID of a mail in the mailbox, empty if the mail has none
+type IMAPHeader struct {
+	UID       int64
+	MessageID string
+}
+
+// Connect, login and select the mailbox of the config
+func DialIMAP(cfg IMAPConfig) (*IMAPClient, error) {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: IMAPTimeout}
+	if cfg.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &IMAPClient{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.open(cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *IMAPClient) open(cfg IMAPConfig) error {
+	// Greeting
+	c.conn.SetDeadline(time.Now().Add(IMAPTimeout))
+	if _, err := c.readLine(); err != nil {
+		return err
+	}
+
+	if _, err := c.cmd("LOGIN %v %v", quote(cfg.User), quote(cfg.Password)); err != nil {
+		return err
+	}
+
+	lines, err := c.cmd("SELECT %v", quote(cfg.mailbox()))
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if m := uidValidityRe.FindStringSubmatch(l.text); m != nil {
+			c.UIDValidity, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		if m := existsRe.FindStringSubmatch(l.text); m != nil {
+			c.Exists, _ = strconv.Atoi(m[1])
+		}
+	}
+
+	return nil
+}
+
+// Logout and close the connection
+func (c *IMAPClient) Close() error {
+	c.cmd("LOGOUT")
+	return c.conn.Close()
+}
+
+// Message-IDs of the mails with a greater UID than uid ordered by UID.
+// Only the header field is fetched, not the mails.
+func (c *IMAPClient) HeadersSince(uid int64) ([]IMAPHeader, error) {
+	if c.Exists == 0 {
+		return []IMAPHeader{}, nil
+	}
+
+	lines, err := c.cmd("UID FETCH %d:* (UID BODY.PEEK[HEADER.FIELDS (MESSAGE-ID)])", uid+1)
+	if err != nil {
+		return nil, err
+	}
+
+	r := []IMAPHeader{}
+	for _, l := range lines {
+		if !strings.Contains(l.text, "FETCH") {
+			continue
+		}
+		m := fetchUIDRe.FindStringSubmatch(l.text)
+		if m == nil {
+			continue
+		}
+		h := IMAPHeader{}
+		h.UID, _ = strconv.ParseInt(m[1], 10, 64)
+		// n:* always contains the last mail, even if its UID is lower
+		if h.UID <= uid {
+			continue
+		}
+
+		if l.literal != nil {
+			header := append(l.literal, '\r', '\n')
+			if msg, err := mail.ReadMessage(bytes.NewReader(header)); err == nil {
+				h.MessageID = strings.TrimSpace(msg.Header.Get("Message-Id"))
+			}
+		}
+
+		r = append(r, h)
+	}
+
+	sort.Slice(r, func(i, j int) bool { return r[i].UID < r[j].UID })
+
+	return r, nil
+}
+
+// Fetch the whole mail with the UID
+func (c *IMAPClient) Fetch(uid int64) ([]byte, error) {
+	lines, err := c.cmd("UID FETCH %d (BODY.PEEK[])", uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		if l.literal != nil && strings.Contains(l.text, "FETCH") {
+			return l.literal, nil
+		}
+	}
+
+	return nil, fmt.Errorf("imap: mail %v not found", uid)
+}
+
+// Response line with the literal which followed it
+type imapLine struct {
+	text    string
+	literal []byte
+}
+
+// Send a command and read the responses up to the tagged one
+func (c *IMAPClient) cmd(format string, args ...interface{}) ([]imapLine, error) {
+	c.conn.SetDeadline(time.Now().Add(IMAPTimeout))
+
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%v %v\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	lines := []imapLine{}
+	for {
+		l, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(l.text, tag+" ") {
+			status := strings.TrimPrefix(l.text, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("imap: %v", status)
+			}
+			return lines, nil
+		}
+
+		lines = append(lines, l)
+	}
+}
+
+// Read a line, a literal {n} at the end of the line is read too
+func (c *IMAPClient) readLine() (imapLine, error) {
+	s, err := c.r.ReadString('\n')
+	if err != nil {
+		return imapLine{}, err
+	}
+	l := imapLine{text: strings.TrimRight(s, "\r\n")}
+
+	if m := literalRe.FindStringSubmatch(l.text); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		l.literal = make([]byte, n)
+		if _, err := io.ReadFull(c.r, l.literal); err != nil {
+			return imapLine{}, err
+		}
+
+		// Rest of the response after the literal, e.g. ")"
+		rest, err := c.r.ReadString('\n')
+		if err != nil {
+			return imapLine{}, err
+		}
+		l.text += strings.TrimRight(rest, "\r\n")
+	}
+
+	return l, nil
+}
+
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
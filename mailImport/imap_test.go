@@ -0,0 +1,241 @@
+package mailImport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+// Stand-in IMAP server which answers the commands of IMAPClient
+type imapServer struct {
+	l     net.Listener
+	mails map[int]string
+
+	mu          sync.Mutex
+	uidValidity int
+	// UIDs whose whole mail was fetched
+	fetched []int
+}
+
+func newIMAPServer(t *testing.T, mails map[int]string) *imapServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &imapServer{l: l, uidValidity: 1, mails: mails}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *imapServer) config(password string) IMAPConfig {
+	return IMAPConfig{
+		Addr:     s.l.Addr().String(),
+		User:     "docma",
+		Password: password,
+	}
+}
+
+func (s *imapServer) fetchedUIDs() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.fetched
+	s.fetched = nil
+	return r
+}
+
+func (s *imapServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+
+	uids := []int{}
+	for uid := range s.mails {
+		uids = append(uids, uid)
+	}
+	sort.Ints(uids)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		tag, cmd := f[0], strings.Join(f[1:], " ")
+
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if cmd != `LOGIN "docma" "secret"` {
+				fmt.Fprintf(conn, "%v NO invalid credentials\r\n", tag)
+				continue
+			}
+		case strings.HasPrefix(cmd, "SELECT"):
+			fmt.Fprintf(conn, "* %d EXISTS\r\n", len(s.mails))
+			s.mu.Lock()
+			fmt.Fprintf(conn, "* OK [UIDVALIDITY %d] UIDs valid\r\n", s.uidValidity)
+			s.mu.Unlock()
+		case strings.HasPrefix(cmd, "UID FETCH") && strings.Contains(cmd, "HEADER.FIELDS"):
+			from := 0
+			fmt.Sscanf(f[3], "%d:*", &from)
+			for i, uid := range uids {
+				// n:* contains the last mail even if its UID is lower
+				if uid < from && i < len(uids)-1 {
+					continue
+				}
+				h := messageIDHeader(s.mails[uid])
+				fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[HEADER.FIELDS (MESSAGE-ID)] {%d}\r\n%v)\r\n", i+1, uid, len(h), h)
+			}
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			uid := 0
+			fmt.Sscanf(f[3], "%d", &uid)
+			s.mu.Lock()
+			s.fetched = append(s.fetched, uid)
+			s.mu.Unlock()
+			m := s.mails[uid]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%v)\r\n", uid, len(m), m)
+		case cmd == "LOGOUT":
+			fmt.Fprint(conn, "* BYE\r\n")
+			fmt.Fprintf(conn, "%v OK LOGOUT completed\r\n", tag)
+			return
+		}
+
+		fmt.Fprintf(conn, "%v OK done\r\n", tag)
+	}
+}
+
+func messageIDHeader(raw string) string {
+	m, err := mail.ReadMessage(bytes.NewBufferString(raw))
+	if err != nil || m.Header.Get("Message-Id") == "" {
+		return "\r\n"
+	}
+
+	return fmt.Sprintf("Message-ID: %v\r\n\r\n", m.Header.Get("Message-Id"))
+}
+
+func Test_IMAPClient(t *testing.T) {
+	mails := map[int]string{7: testMail, 9: "Subject: hi\r\n\r\nno attachment\r\n"}
+	s := newIMAPServer(t, mails)
+	defer s.l.Close()
+
+	c, err := DialIMAP(s.config("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if c.UIDValidity != 1 || c.Exists != 2 {
+		t.Fatalf("Unexpected mailbox %v %v", c.UIDValidity, c.Exists)
+	}
+
+	h, err := c.HeadersSince(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []IMAPHeader{{UID: 7, MessageID: "<42@strato.de>"}, {UID: 9}}
+	if fmt.Sprint(h) != fmt.Sprint(expect) {
+		t.Fatalf("Expect %v was %v", expect, h)
+	}
+
+	// The last mail is not returned again
+	h, err = c.HeadersSince(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 0 {
+		t.Fatalf("Expect no headers was %v", h)
+	}
+
+	b, err := c.Fetch(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testMail {
+		t.Fatalf("Unexpected mail %v", string(b))
+	}
+}
+
+func Test_DialIMAP_LoginFailed(t *testing.T) {
+	s := newIMAPServer(t, map[int]string{})
+	defer s.l.Close()
+
+	if _, err := DialIMAP(s.config("wrong")); err == nil {
+		t.Fatal("Expect login error")
+	}
+}
+
+func Test_ImportIMAP(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, docs.AddTables, labels.AddTables)
+
+	files, err := ioutil.TempDir("", "mailImport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(files)
+
+	mails := map[int]string{7: testMail, 9: "Subject: hi\r\n\r\nno attachment\r\n"}
+	s := newIMAPServer(t, mails)
+	defer s.l.Close()
+
+	i := Importer{DB: db, Files: files}
+	r, err := i.ImportIMAP(s.config("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 2 || len(r.DocIDs) != 1 || len(r.Failed) != 0 {
+		t.Fatalf("Unexpected result %v", r)
+	}
+	if f := s.fetchedUIDs(); fmt.Sprint(f) != "[7 9]" {
+		t.Fatalf("Expect [7 9] was %v", f)
+	}
+
+	// Nothing is fetched on the second run
+	r, err = i.ImportIMAP(s.config("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 0 || r.Skipped != 0 {
+		t.Fatalf("Unexpected result %v", r)
+	}
+	if f := s.fetchedUIDs(); len(f) != 0 {
+		t.Fatalf("Expect no fetched mails was %v", f)
+	}
+
+	// Renumbered mails are skipped by their Message-ID, only the mail
+	// without Message-ID is fetched
+	s.mu.Lock()
+	s.uidValidity = 2
+	s.mu.Unlock()
+	r, err = i.ImportIMAP(s.config("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 0 || r.Skipped != 2 {
+		t.Fatalf("Unexpected result %v", r)
+	}
+	if f := s.fetchedUIDs(); fmt.Sprint(f) != "[9]" {
+		t.Fatalf("Expect [9] was %v", f)
+	}
+}
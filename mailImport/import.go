@@ -0,0 +1,291 @@
+package mailImport
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tochti/docMa-handler/docs"
+	"gopkg.in/gorp.v1"
+)
+
+func AddTables(db *gorp.DbMap) {
+	db.AddTableWithName(MailImport{}, MailImportsTable).
+		SetKeys(false, "message_id")
+
+	db.AddTableWithName(IMAPState{}, IMAPStatesTable).
+		SetKeys(false, "mailbox")
+}
+
+type Importer struct {
+	DB    *gorp.DbMap
+	Files string
+}
+
+// Store the PDF attachments of the mail as docs. It returns false if the
+// mail was imported before.
+func (i Importer) ImportMessage(raw []byte) ([]docs.Doc, bool, error) {
+	msg, err := ParseMessage(raw)
+	if err != nil {
+		return []docs.Doc{}, false, err
+	}
+
+	known, err := i.imported(msg.ID)
+	if err != nil {
+		return []docs.Doc{}, false, err
+	}
+	if known {
+		return []docs.Doc{}, false, nil
+	}
+
+	r := []docs.Doc{}
+	for _, a := range msg.Attachments {
+		doc, err := i.storeAttachment(msg, a)
+		if err != nil {
+			// Remove the docs of the mail so a retry doesn't duplicate them
+			i.removeDocs(r)
+			return []docs.Doc{}, false, err
+		}
+		r = append(r, doc)
+	}
+
+	mi := MailImport{
+		MessageID:  msg.ID,
+		ImportedAt: time.Now(),
+		Docs:       len(r),
+	}
+	if err := i.DB.Insert(&mi); err != nil {
+		i.removeDocs(r)
+		return []docs.Doc{}, false, err
+	}
+
+	return r, true, nil
+}
+
+func (i Importer) imported(messageID string) (bool, error) {
+	q := Q("SELECT COUNT(*) FROM %v WHERE message_id=?", MailImportsTable)
+	n, err := i.DB.SelectInt(q, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (i Importer) storeAttachment(msg Message, a Attachment) (docs.Doc, error) {
+	name, err := docs.FreeName(i.DB, i.Files, a.Name)
+	if err != nil {
+		return docs.Doc{}, err
+	}
+
+	filename := path.Join(i.Files, name)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return docs.Doc{}, err
+	}
+	_, err = f.Write(a.Data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(filename)
+		return docs.Doc{}, err
+	}
+
+	doc := docs.Doc{
+		Name:          name,
+		DateOfScan:    time.Now(),
+		DateOfReceipt: msg.Date,
+		Note:          fmt.Sprintf("From: %v\nSubject: %v", msg.From, msg.Subject),
+	}
+	if err := docs.CreateDoc(i.DB, &doc); err != nil {
+		os.Remove(filename)
+		return docs.Doc{}, err
+	}
+
+	return doc, nil
+}
+
+// Remove the docs with their labels, the files of docs which can't be
+// removed are kept
+func (i Importer) removeDocs(l []docs.Doc) {
+	for _, d := range l {
+		if err := docs.RemoveDoc(i.DB, d.ID); err != nil {
+			log.Printf("can't remove doc %v: %v", d.ID, err)
+			continue
+		}
+		os.Remove(path.Join(i.Files, d.Name))
+	}
+}
+
+// Import all mails of the Maildir. Read mails are moved from new to cur
+// like a mail client does.
+func (i Importer) ImportMaildir(dir string) (Result, error) {
+	r := newResult()
+
+	// cur first, so mails moved from new are not read twice
+	for _, sub := range []string{"cur", "new"} {
+		infos, err := ioutil.ReadDir(path.Join(dir, sub))
+		if err != nil {
+			return r, err
+		}
+
+		for _, info := range infos {
+			if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+
+			filename := path.Join(dir, sub, info.Name())
+			raw, err := ioutil.ReadFile(filename)
+			if err != nil {
+				r.Failed[info.Name()] = err.Error()
+				continue
+			}
+
+			i.record(&r, info.Name(), raw)
+
+			if sub == "new" {
+				cur := path.Join(dir, "cur", info.Name()+":2,S")
+				if err := os.Rename(filename, cur); err != nil {
+					r.Failed[info.Name()] = err.Error()
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// Import the new mails of the IMAP mailbox, the mails are not changed on
+// the server. The UID of the last imported mail is stored, so a run only
+// reads the mails which arrived since. Mails whose Message-ID was
+// imported before are skipped without fetching them.
+func (i Importer) ImportIMAP(cfg IMAPConfig) (Result, error) {
+	r := newResult()
+
+	state, err := i.readIMAPState(cfg)
+	if err != nil {
+		return r, err
+	}
+
+	c, err := DialIMAP(cfg)
+	if err != nil {
+		return r, err
+	}
+	defer c.Close()
+
+	if state.UIDValidity != c.UIDValidity {
+		// The server renumbered the mails, the Message-IDs still keep
+		// them from being imported twice
+		state.UIDValidity = c.UIDValidity
+		state.LastUID = 0
+	}
+
+	headers, err := c.HeadersSince(state.LastUID)
+	if err != nil {
+		return r, err
+	}
+
+	// A failed mail and all mails after it are read again on the next
+	// run, the mails after it are skipped then
+	failed := false
+	for _, h := range headers {
+		ok, err := i.importIMAPMail(c, &r, h)
+		if err != nil {
+			// Keep the progress up to the lost connection
+			if err1 := i.saveIMAPState(state); err1 != nil {
+				log.Printf("can't save imap state: %v", err1)
+			}
+			return r, err
+		}
+
+		failed = failed || !ok
+		if !failed {
+			state.LastUID = h.UID
+		}
+	}
+
+	return r, i.saveIMAPState(state)
+}
+
+// Import one mail, false if the import failed. Errors are only returned
+// if the connection to the server broke.
+func (i Importer) importIMAPMail(c *IMAPClient, r *Result, h IMAPHeader) (bool, error) {
+	key := fmt.Sprintf("uid %v", h.UID)
+
+	if h.MessageID != "" {
+		known, err := i.imported(h.MessageID)
+		if err != nil {
+			r.Failed[key] = err.Error()
+			return false, nil
+		}
+		if known {
+			r.Skipped++
+			return true, nil
+		}
+	}
+
+	raw, err := c.Fetch(h.UID)
+	if err != nil {
+		return false, err
+	}
+
+	i.record(r, key, raw)
+	_, failed := r.Failed[key]
+
+	return !failed, nil
+}
+
+func imapStateKey(cfg IMAPConfig) string {
+	return fmt.Sprintf("%v@%v/%v", cfg.User, cfg.Addr, cfg.mailbox())
+}
+
+func (i Importer) readIMAPState(cfg IMAPConfig) (IMAPState, error) {
+	state := IMAPState{}
+	q := Q("SELECT * FROM %v WHERE mailbox=?", IMAPStatesTable)
+	err := i.DB.SelectOne(&state, q, imapStateKey(cfg))
+	if err == sql.ErrNoRows {
+		return IMAPState{Mailbox: imapStateKey(cfg)}, nil
+	}
+	if err != nil {
+		return IMAPState{}, err
+	}
+
+	return state, nil
+}
+
+func (i Importer) saveIMAPState(state IMAPState) error {
+	q := Q(`
+	INSERT INTO %v (mailbox, uid_validity, last_uid) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE
+		uid_validity=VALUES(uid_validity),
+		last_uid=VALUES(last_uid)`, IMAPStatesTable)
+	_, err := i.DB.Exec(q, state.Mailbox, state.UIDValidity, state.LastUID)
+
+	return err
+}
+
+func (i Importer) record(r *Result, key string, raw []byte) {
+	l, imported, err := i.ImportMessage(raw)
+	for _, d := range l {
+		r.DocIDs = append(r.DocIDs, d.ID)
+	}
+
+	switch {
+	case err != nil:
+		r.Failed[key] = err.Error()
+	case imported:
+		r.Imported++
+	default:
+		r.Skipped++
+	}
+}
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
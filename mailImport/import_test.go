@@ -0,0 +1,82 @@
+package mailImport
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+)
+
+func Test_ImportMaildir(t *testing.T) {
+	db := common.InitTestDB(t, AddTables, docs.AddTables, labels.AddTables)
+
+	root, err := ioutil.TempDir("", "mailImport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	maildir := path.Join(root, "Maildir")
+	files := path.Join(root, "files")
+	for _, dir := range []string{"new", "cur", "tmp"} {
+		if err := os.MkdirAll(path.Join(maildir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(files, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path.Join(maildir, "new", "1.host"), []byte(testMail), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same mail delivered twice
+	err = ioutil.WriteFile(path.Join(maildir, "new", "2.host"), []byte(testMail), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := Importer{DB: db, Files: files}
+	r, err := i.ImportMaildir(maildir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Imported != 1 || r.Skipped != 1 || len(r.DocIDs) != 1 || len(r.Failed) != 0 {
+		t.Fatalf("Unexpected result %v", r)
+	}
+
+	doc := docs.Doc{}
+	if err := db.SelectOne(&doc, "SELECT * FROM docs WHERE id=?", r.DocIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "rechnung.pdf" {
+		t.Fatalf("Expect %v was %v", "rechnung.pdf", doc.Name)
+	}
+	if doc.Note != "From: Strato AG <rechnung@strato.de>\nSubject: Ihre Rechnung für Mai" {
+		t.Fatalf("Unexpected note %v", doc.Note)
+	}
+	date := time.Date(2016, time.May, 2, 8, 0, 0, 0, time.UTC)
+	if !doc.DateOfReceipt.Equal(date) {
+		t.Fatalf("Expect %v was %v", date, doc.DateOfReceipt)
+	}
+
+	if _, err := os.Stat(path.Join(maildir, "cur", "1.host:2,S")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing new on the second run
+	r, err = i.ImportMaildir(maildir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Imported != 0 || r.Skipped != 2 {
+		t.Fatalf("Unexpected result %v", r)
+	}
+}
@@ -0,0 +1,58 @@
+// Package mailImport stores PDF attachments of mails as docs. Mails are
+// read from a Maildir or an IMAP mailbox.
+package mailImport
+
+import "time"
+
+var (
+	MailImportsTable = "mail_imports"
+	IMAPStatesTable  = "imap_states"
+)
+
+type (
+	// Imported mail, a mail is never imported twice
+	MailImport struct {
+		MessageID  string    `db:"message_id" json:"message_id"`
+		ImportedAt time.Time `db:"imported_at" json:"imported_at"`
+		Docs       int       `db:"docs" json:"docs"`
+	}
+
+	// Position of the import in an IMAP mailbox
+	IMAPState struct {
+		// User, address and name of the mailbox
+		Mailbox     string `db:"mailbox"`
+		UIDValidity int64  `db:"uid_validity"`
+		// Mails up to this UID are imported
+		LastUID int64 `db:"last_uid"`
+	}
+
+	Message struct {
+		ID          string
+		From        string
+		Subject     string
+		Date        time.Time
+		Attachments []Attachment
+	}
+
+	Attachment struct {
+		Name string
+		Data []byte
+	}
+
+	Result struct {
+		// Number of imported and of already imported mails
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+		// Created docs
+		DocIDs []int64 `json:"doc_ids"`
+		// Errors by mail
+		Failed map[string]string `json:"failed"`
+	}
+)
+
+func newResult() Result {
+	return Result{
+		DocIDs: []int64{},
+		Failed: map[string]string{},
+	}
+}
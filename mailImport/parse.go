@@ -0,0 +1,132 @@
+package mailImport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path"
+	"strings"
+)
+
+var wordDecoder = mime.WordDecoder{}
+
+// Parse a mail and collect its PDF attachments. Mails without Message-ID
+// get an ID from the hash of their content.
+func ParseMessage(raw []byte) (Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		ID:          strings.TrimSpace(m.Header.Get("Message-Id")),
+		From:        decodeHeader(m.Header.Get("From")),
+		Subject:     decodeHeader(m.Header.Get("Subject")),
+		Attachments: []Attachment{},
+	}
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+	}
+
+	if d, err := m.Header.Date(); err == nil {
+		msg.Date = d
+	}
+
+	err = collectAttachments(
+		&msg,
+		m.Header.Get("Content-Type"),
+		m.Header.Get("Content-Disposition"),
+		m.Header.Get("Content-Transfer-Encoding"),
+		m.Body,
+	)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return msg, nil
+}
+
+func collectAttachments(msg *Message, contentType, disposition, encoding string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = collectAttachments(
+				msg,
+				p.Header.Get("Content-Type"),
+				p.Header.Get("Content-Disposition"),
+				p.Header.Get("Content-Transfer-Encoding"),
+				p,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	name := params["name"]
+	if _, dParams, err := mime.ParseMediaType(disposition); err == nil && dParams["filename"] != "" {
+		name = dParams["filename"]
+	}
+	name = decodeHeader(name)
+
+	isPDF := mediaType == "application/pdf" ||
+		strings.ToLower(path.Ext(name)) == ".pdf"
+	if !isPDF {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(decodeBody(encoding, body))
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("attachment-%v.pdf", len(msg.Attachments)+1)
+	}
+	msg.Attachments = append(msg.Attachments, Attachment{
+		Name: path.Base(name),
+		Data: b,
+	})
+
+	return nil
+}
+
+func decodeBody(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func decodeHeader(s string) string {
+	d, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+
+	return d
+}
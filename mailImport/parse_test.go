@@ -0,0 +1,72 @@
+package mailImport
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMail = "From: Strato AG <rechnung@strato.de>\r\n" +
+	"Subject: =?UTF-8?Q?Ihre_Rechnung_f=C3=BCr_Mai?=\r\n" +
+	"Date: Mon, 02 May 2016 10:00:00 +0200\r\n" +
+	"Message-ID: <42@strato.de>\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Anbei die Rechnung.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: application/pdf; name=\"rechnung.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"rechnung.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQg\r\nUmVjaG51bmc=\r\n" +
+	"--b1--\r\n"
+
+func Test_ParseMessage(t *testing.T) {
+	msg, err := ParseMessage([]byte(testMail))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.ID != "<42@strato.de>" {
+		t.Fatalf("Expect %v was %v", "<42@strato.de>", msg.ID)
+	}
+	if msg.Subject != "Ihre Rechnung für Mai" {
+		t.Fatalf("Expect %v was %v", "Ihre Rechnung für Mai", msg.Subject)
+	}
+	if msg.From != "Strato AG <rechnung@strato.de>" {
+		t.Fatalf("Expect %v was %v", "Strato AG <rechnung@strato.de>", msg.From)
+	}
+
+	date := time.Date(2016, time.May, 2, 8, 0, 0, 0, time.UTC)
+	if !msg.Date.Equal(date) {
+		t.Fatalf("Expect %v was %v", date, msg.Date)
+	}
+
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("Expect %v was %v", 1, len(msg.Attachments))
+	}
+	a := msg.Attachments[0]
+	if a.Name != "rechnung.pdf" || string(a.Data) != "%PDF-1.4 Rechnung" {
+		t.Fatalf("Unexpected attachment %v %q", a.Name, a.Data)
+	}
+}
+
+func Test_ParseMessage_WithoutMessageID(t *testing.T) {
+	raw := strings.Replace(testMail, "Message-ID: <42@strato.de>\r\n", "", 1)
+
+	msg1, err := ParseMessage([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg2, err := ParseMessage([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(msg1.ID, "sha256:") || msg1.ID != msg2.ID {
+		t.Fatalf("Expect equal hash ids was %v and %v", msg1.ID, msg2.ID)
+	}
+}
@@ -0,0 +1,172 @@
+// Package pdfTools wraps the qpdf command line tool, it has to be
+// installed to split or merge files. Call Check at startup to find out
+// early if it is missing.
+package pdfTools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	// Path of the qpdf binary
+	QPDF = "qpdf"
+
+	ErrInvalidRange = errors.New("invalid page range")
+	ErrQPDFMissing  = errors.New("qpdf is not installed, it is needed to split and merge PDFs")
+)
+
+// Check that qpdf can be run
+func Check() error {
+	if _, err := exec.LookPath(QPDF); err != nil {
+		return ErrQPDFMissing
+	}
+
+	return nil
+}
+
+// Pages From to To, both included and counted from 1
+type PageRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (r PageRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From)
+	}
+	return fmt.Sprintf("%v-%v", r.From, r.To)
+}
+
+// Parse a list of page ranges like "1-3,4,5-6"
+func ParsePageRanges(s string) ([]PageRange, error) {
+	l := []PageRange{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(p, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, ErrInvalidRange
+		}
+		to := from
+		if len(bounds) == 2 {
+			to, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return nil, ErrInvalidRange
+			}
+		}
+
+		r := PageRange{From: from, To: to}
+		if r.From < 1 || r.To < r.From {
+			return nil, ErrInvalidRange
+		}
+		l = append(l, r)
+	}
+
+	if len(l) == 0 {
+		return nil, ErrInvalidRange
+	}
+
+	return l, nil
+}
+
+// Check that all ranges are within the pages of a file
+func CheckPageRanges(ranges []PageRange, pages int) error {
+	for _, r := range ranges {
+		if r.From < 1 || r.To < r.From || r.To > pages {
+			return fmt.Errorf("%v: %v of %v pages", ErrInvalidRange, r, pages)
+		}
+	}
+
+	return nil
+}
+
+// Number of pages of the file
+func PageCount(file string) (int, error) {
+	out, err := run("--show-npages", file)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// Write the pages of the ranges to a new file
+func ExtractPages(file string, ranges []PageRange, out string) error {
+	l := []string{}
+	for _, r := range ranges {
+		l = append(l, r.String())
+	}
+
+	_, err := run("--empty", "--pages", file, strings.Join(l, ","), "--", out)
+	return err
+}
+
+// Return a page as a PDF file of its own
+func ReadPage(file string, page int) ([]byte, error) {
+	f, err := ioutil.TempFile("", "page")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	err = ExtractPages(file, []PageRange{{From: page, To: page}}, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(f.Name())
+}
+
+// Append all files in the given order to a new file
+func Merge(files []string, out string) error {
+	if len(files) == 0 {
+		return errors.New("no files to merge")
+	}
+
+	args := append([]string{"--empty", "--pages"}, files...)
+	args = append(args, "--", out)
+	_, err := run(args...)
+	return err
+}
+
+func run(args ...string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(QPDF, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if _, ok := err.(*exec.Error); ok || os.IsNotExist(err) {
+		return nil, ErrQPDFMissing
+	}
+	// Exit code 3 means success with warnings
+	if exitErr, ok := err.(*exec.ExitError); ok && exitCode(exitErr) == 3 {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("qpdf: %v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func exitCode(err *exec.ExitError) int {
+	if s, ok := err.Sys().(syscall.WaitStatus); ok {
+		return s.ExitStatus()
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+package pdfTools
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParsePageRanges(t *testing.T) {
+	r, err := ParsePageRanges("1-3, 4,5-6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []PageRange{{1, 3}, {4, 4}, {5, 6}}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	for _, s := range []string{"", "0", "3-1", "a-2", "1-", ","} {
+		if _, err := ParsePageRanges(s); err != ErrInvalidRange {
+			t.Fatalf("Expect %v for %q was %v", ErrInvalidRange, s, err)
+		}
+	}
+}
+
+func Test_CheckPageRanges(t *testing.T) {
+	if err := CheckPageRanges([]PageRange{{1, 2}, {3, 3}}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPageRanges([]PageRange{{2, 4}}, 3); err == nil {
+		t.Fatal("Expect error")
+	}
+}
+
+// Write a PDF with one page per text
+func WritePDF(t *testing.T, file string, texts ...string) {
+	objs := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := []string{}
+	for _, text := range texts {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%v) Tj ET", text)
+		objs = append(objs,
+			fmt.Sprintf("<< /Length %v >>\nstream\n%v\nendstream", len(content), content),
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents %v 0 R >>", len(objs)+1),
+		)
+		kids = append(kids, fmt.Sprintf("%v 0 R", len(objs)))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), len(kids))
+
+	b := &bytes.Buffer{}
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, o := range objs {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(b, "%v 0 obj\n%v\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(b, "xref\n0 %v\n0000000000 65535 f \n", len(objs)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(b, "trailer\n<< /Size %v /Root 1 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(objs)+1, xref)
+
+	if err := ioutil.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExtractPages_Merge(t *testing.T) {
+	if _, err := exec.LookPath(QPDF); err != nil {
+		t.Skip("qpdf is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "pdfTools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := path.Join(dir, "in.pdf")
+	WritePDF(t, in, "one", "two", "three")
+
+	n, err := PageCount(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("Expect %v was %v", 3, n)
+	}
+
+	out := path.Join(dir, "out.pdf")
+	if err := ExtractPages(in, []PageRange{{2, 3}}, out); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := PageCount(out); n != 2 {
+		t.Fatalf("Expect %v was %v", 2, n)
+	}
+
+	merged := path.Join(dir, "merged.pdf")
+	if err := Merge([]string{in, out}, merged); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := PageCount(merged); n != 5 {
+		t.Fatalf("Expect %v was %v", 5, n)
+	}
+
+	page, err := ReadPage(in, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "three") {
+		t.Fatalf("Expect page three was %s", page)
+	}
+}
+
+func Test_QPDFMissing(t *testing.T) {
+	defer func(q string) { QPDF = q }(QPDF)
+	QPDF = "/nonexistent/qpdf"
+
+	if err := Check(); err != ErrQPDFMissing {
+		t.Fatalf("Expect %v was %v", ErrQPDFMissing, err)
+	}
+	if _, err := PageCount("in.pdf"); err != ErrQPDFMissing {
+		t.Fatalf("Expect %v was %v", ErrQPDFMissing, err)
+	}
+}
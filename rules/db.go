@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+func AddTables(db *gorp.DbMap) {
+	tMap := db.AddTableWithName(Rule{}, RulesTable).
+		SetKeys(true, "id")
+	tMap.ColMap("name").SetNotNull(true)
+	tMap.ColMap("conditions").SetMaxSize(4096)
+	tMap.ColMap("label_ids").SetMaxSize(1024)
+}
+
+func ReadRule(db *gorp.DbMap, id int64) (Rule, error) {
+	r := Rule{}
+	q := Q("SELECT * FROM %v WHERE id=?", RulesTable)
+	if err := db.SelectOne(&r, q, id); err != nil {
+		return Rule{}, err
+	}
+
+	if err := r.decode(); err != nil {
+		return Rule{}, err
+	}
+
+	return r, nil
+}
+
+func ReadAllRules(db *gorp.DbMap) ([]Rule, error) {
+	return readRules(db, Q("SELECT * FROM %v ORDER BY id", RulesTable))
+}
+
+func ReadEnabledRules(db *gorp.DbMap) ([]Rule, error) {
+	return readRules(db, Q("SELECT * FROM %v WHERE enabled=true ORDER BY id", RulesTable))
+}
+
+func readRules(db *gorp.DbMap, q string) ([]Rule, error) {
+	l := []Rule{}
+	if _, err := db.Select(&l, q); err != nil {
+		return []Rule{}, err
+	}
+
+	for i := range l {
+		if err := l[i].decode(); err != nil {
+			return []Rule{}, err
+		}
+	}
+
+	return l, nil
+}
+
+// Check that all labels of the rule exist
+func checkLabels(db *gorp.DbMap, r Rule) error {
+	for _, id := range r.LabelIDs {
+		q := Q("SELECT COUNT(*) FROM %v WHERE id=?", labels.LabelsTable)
+		n, err := db.SelectInt(q, id)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("label %v not found", id)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) encode() error {
+	b, err := json.Marshal(r.Conditions)
+	if err != nil {
+		return err
+	}
+	r.EncodedConditions = string(b)
+
+	b, err = json.Marshal(r.LabelIDs)
+	if err != nil {
+		return err
+	}
+	r.EncodedLabelIDs = string(b)
+
+	return nil
+}
+
+func (r *Rule) decode() error {
+	if err := json.Unmarshal([]byte(r.EncodedConditions), &r.Conditions); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(r.EncodedLabelIDs), &r.LabelIDs)
+}
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
@@ -0,0 +1,184 @@
+package rules
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/valid"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+func CreateRuleHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	r := Rule{}
+	if err := ginCtx.BindJSON(&r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r.ID = 0
+	if err := saveRule(db, &r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, r)
+}
+
+func ReadAllRulesHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	l, err := ReadAllRules(db)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+func ReadOneRuleHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadRuleID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	r, err := ReadRule(db, id)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func UpdateRuleHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadRuleID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	if _, err := ReadRule(db, id); err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	r := Rule{}
+	if err := ginCtx.BindJSON(&r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r.ID = id
+	if err := saveRule(db, &r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func DeleteRuleHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadRuleID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	r, err := ReadRule(db, id)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	if _, err := db.Delete(&r); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, nil)
+}
+
+// Run all enabled rules over the whole archive. With the query parameter
+// rule_id only the given rule runs, even if it isn't enabled yet. If
+// dry_run is true the labels are only reported and not attached.
+func RunRulesHandler(ginCtx *gin.Context, db *gorp.DbMap, files string) {
+	var rules []Rule
+	var err error
+	if tmp := ginCtx.Query("rule_id"); tmp != "" {
+		id, err := strconv.ParseInt(tmp, 10, 64)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+
+		r, err := ReadRule(db, id)
+		if err != nil {
+			errorResponse(ginCtx, err)
+			return
+		}
+		rules = []Rule{r}
+	} else {
+		rules, err = ReadEnabledRules(db)
+		if err != nil {
+			gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	dryRun := ginCtx.Query("dry_run") == "true"
+	r, err := RunRules(db, rules, SidecarText{Dir: files}, dryRun)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func ReadRuleID(c *gin.Context) (int64, error) {
+	tmp := c.Params.ByName("ruleID")
+	id, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil {
+		gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Validate and insert or update the rule
+func saveRule(db *gorp.DbMap, r *Rule) error {
+	if err := valid.Struct(*r); err != nil {
+		return err
+	}
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	if err := checkLabels(db, *r); err != nil {
+		return err
+	}
+
+	if err := r.encode(); err != nil {
+		return err
+	}
+
+	if r.ID == 0 {
+		return db.Insert(r)
+	}
+
+	_, err := db.Update(r)
+	return err
+}
+
+func errorResponse(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+		return
+	}
+
+	gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+}
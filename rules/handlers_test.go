@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+)
+
+func Test_CreateRuleHandler(t *testing.T) {
+	db := initDB(t)
+
+	if err := db.Insert(&labels.Label{ID: 1, Name: "Hosting"}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `
+	{
+		"name": "Hosting",
+		"enabled": true,
+		"conditions": [{"field": "name", "op": "contains", "value": "strato"}],
+		"label_ids": [1]
+	}
+	`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateRuleHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", body)
+
+	expect := Rule{
+		ID:         1,
+		Name:       "Hosting",
+		Enabled:    true,
+		Conditions: []Condition{{Field: FieldName, Op: OpContains, Value: "strato"}},
+		LabelIDs:   []int64{1},
+	}
+	expectResp := gumtest.JSONResponse{http.StatusCreated, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := ReadRule(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rule.Conditions) != 1 || rule.LabelIDs[0] != 1 {
+		t.Fatalf("Unexpected rule %v", rule)
+	}
+}
+
+func Test_CreateRuleHandler_UnknownLabel(t *testing.T) {
+	db := initDB(t)
+
+	body := `
+	{
+		"name": "Hosting",
+		"conditions": [{"field": "name", "op": "contains", "value": "strato"}],
+		"label_ids": [1]
+	}
+	`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateRuleHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("POST", "/", body)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
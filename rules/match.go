@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tochti/docMa-handler/docs"
+)
+
+var (
+	ErrNoConditions = errors.New("rule needs at least one condition")
+	ErrNoLabels     = errors.New("rule needs at least one label")
+)
+
+// Check that the rule has conditions and labels and that all fields and
+// operators are known
+func (r Rule) Validate() error {
+	if len(r.Conditions) == 0 {
+		return ErrNoConditions
+	}
+
+	if len(r.LabelIDs) == 0 {
+		return ErrNoLabels
+	}
+
+	for _, c := range r.Conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Condition) Validate() error {
+	switch c.Field {
+	case FieldName, FieldBarcode, FieldNote, FieldDocNumber, FieldText,
+		FieldAccountNumber, FieldPostingText:
+	default:
+		return fmt.Errorf("unknown field %v", c.Field)
+	}
+
+	switch c.Op {
+	case OpEquals, OpContains, OpPrefix:
+	case OpRegexp:
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown operator %v", c.Op)
+	}
+
+	return nil
+}
+
+// True if one of the values matches the condition
+func (c Condition) Match(values []string) bool {
+	var re *regexp.Regexp
+	if c.Op == OpRegexp {
+		var err error
+		re, err = regexp.Compile(c.Value)
+		if err != nil {
+			return false
+		}
+	}
+
+	want := strings.ToLower(c.Value)
+	for _, v := range values {
+		switch c.Op {
+		case OpEquals:
+			if strings.ToLower(v) == want {
+				return true
+			}
+		case OpContains:
+			if strings.Contains(strings.ToLower(v), want) {
+				return true
+			}
+		case OpPrefix:
+			if strings.HasPrefix(strings.ToLower(v), want) {
+				return true
+			}
+		case OpRegexp:
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Match the doc against all conditions of the rule. The text of the doc
+// is only read if a condition needs it.
+func (r Rule) Match(doc docs.ExpandedDoc, text func() (string, error)) (bool, error) {
+	for _, c := range r.Conditions {
+		var values []string
+		if c.Field == FieldText {
+			t, err := text()
+			if err != nil {
+				return false, err
+			}
+			values = []string{t}
+		} else {
+			values = fieldValues(doc, c.Field)
+		}
+
+		if !c.Match(values) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func fieldValues(doc docs.ExpandedDoc, field string) []string {
+	r := []string{}
+
+	switch field {
+	case FieldName:
+		r = append(r, doc.Name)
+	case FieldBarcode:
+		r = append(r, string(doc.Barcode))
+	case FieldNote:
+		r = append(r, doc.Note)
+	case FieldDocNumber:
+		for _, n := range doc.DocNumbers {
+			r = append(r, n.Number)
+		}
+	case FieldAccountNumber:
+		// The account of the doc and both accounts of every linked booking
+		if doc.AccountData != nil {
+			r = append(r, strconv.Itoa(doc.AccountData.AccountNumber))
+		}
+		for _, a := range doc.AccountingData {
+			r = append(r,
+				strconv.Itoa(a.DebitAccount),
+				strconv.Itoa(a.CreditAccount),
+			)
+		}
+	case FieldPostingText:
+		for _, a := range doc.AccountingData {
+			r = append(r, a.PostingText)
+		}
+	}
+
+	return r
+}
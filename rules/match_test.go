@@ -0,0 +1,96 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/docs"
+)
+
+func Test_ConditionMatch(t *testing.T) {
+	tests := []struct {
+		cond   Condition
+		values []string
+		expect bool
+	}{
+		{Condition{Op: OpContains, Value: "strato"}, []string{"Rechnung STRATO.pdf"}, true},
+		{Condition{Op: OpContains, Value: "strato"}, []string{"Rechnung.pdf"}, false},
+		{Condition{Op: OpEquals, Value: "b6"}, []string{"A1", "B6"}, true},
+		{Condition{Op: OpPrefix, Value: "14"}, []string{"1400"}, true},
+		{Condition{Op: OpPrefix, Value: "14"}, []string{"4140"}, false},
+		{Condition{Op: OpRegexp, Value: `^\d{4}-\d{2}`}, []string{"2016-01 Miete"}, true},
+		{Condition{Op: OpRegexp, Value: `^\d{4}-\d{2}`}, []string{}, false},
+	}
+
+	for i, test := range tests {
+		if r := test.cond.Match(test.values); r != test.expect {
+			t.Fatalf("Expect %v was %v in test %v", test.expect, r, i)
+		}
+	}
+}
+
+func Test_RuleValidate(t *testing.T) {
+	rule := Rule{
+		Name:       "Hosting",
+		Conditions: []Condition{{Field: FieldName, Op: OpContains, Value: "strato"}},
+		LabelIDs:   []int64{1},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rule.Conditions[0].Field = "size"
+	if err := rule.Validate(); err == nil {
+		t.Fatal("Expect error for unknown field")
+	}
+
+	rule.Conditions[0] = Condition{Field: FieldName, Op: OpRegexp, Value: "("}
+	if err := rule.Validate(); err == nil {
+		t.Fatal("Expect error for bad regexp")
+	}
+
+	rule.Conditions = []Condition{}
+	if err := rule.Validate(); err != ErrNoConditions {
+		t.Fatalf("Expect %v was %v", ErrNoConditions, err)
+	}
+}
+
+func Test_RuleMatch(t *testing.T) {
+	doc := docs.ExpandedDoc{
+		Doc:         docs.Doc{ID: 1, Name: "strato.pdf"},
+		AccountData: &docs.DocAccountData{AccountNumber: 1400},
+		AccountingData: []accountingData.AccountingData{
+			{DebitAccount: 4930, CreditAccount: 1200, PostingText: "Webhosting"},
+		},
+	}
+
+	rule := Rule{
+		Conditions: []Condition{
+			{Field: FieldName, Op: OpContains, Value: "strato"},
+			{Field: FieldAccountNumber, Op: OpEquals, Value: "4930"},
+			{Field: FieldPostingText, Op: OpContains, Value: "hosting"},
+		},
+	}
+
+	noText := func() (string, error) {
+		t.Fatal("Expect text is not read")
+		return "", nil
+	}
+
+	ok, err := rule.Match(doc, noText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expect rule to match")
+	}
+
+	rule.Conditions = append(rule.Conditions, Condition{Field: FieldText, Op: OpContains, Value: "Vertrag"})
+	ok, err = rule.Match(doc, func() (string, error) { return "Rechnung", nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expect rule not to match")
+	}
+}
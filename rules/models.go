@@ -0,0 +1,59 @@
+package rules
+
+var (
+	RulesTable = "rules"
+)
+
+// Fields a condition can look at
+const (
+	FieldName          = "name"
+	FieldBarcode       = "barcode"
+	FieldNote          = "note"
+	FieldDocNumber     = "doc_number"
+	FieldText          = "text"
+	FieldAccountNumber = "account_number"
+	FieldPostingText   = "posting_text"
+)
+
+// Operators of a condition, all except regexp ignore the case
+const (
+	OpEquals   = "equals"
+	OpContains = "contains"
+	OpPrefix   = "prefix"
+	OpRegexp   = "regexp"
+)
+
+// A rule attaches its labels to every doc which matches all conditions
+type Rule struct {
+	ID         int64       `db:"id" json:"id"`
+	Name       string      `db:"name" json:"name" valid:"required"`
+	Enabled    bool        `db:"enabled" json:"enabled"`
+	Conditions []Condition `db:"-" json:"conditions"`
+	LabelIDs   []int64     `db:"-" json:"label_ids"`
+
+	// JSON encoded conditions and label ids
+	EncodedConditions string `db:"conditions" json:"-"`
+	EncodedLabelIDs   string `db:"label_ids" json:"-"`
+}
+
+type Condition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Labels attached or, in a dry run, labels which would be attached to a
+// doc by a rule
+type Match struct {
+	DocID    int64   `json:"doc_id"`
+	RuleID   int64   `json:"rule_id"`
+	LabelIDs []int64 `json:"label_ids"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type RunResult struct {
+	DryRun bool `json:"dry_run"`
+	// Number of checked docs
+	Docs    int     `json:"docs"`
+	Matches []Match `json:"matches"`
+}
@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"sync"
+
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/docMa-handler/settings"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	// Setting with the number of docs read at once when the rules run
+	// over the archive
+	BatchSizeSetting = "rules.batchSize"
+)
+
+func init() {
+	settings.Register(settings.Setting{
+		Name:        BatchSizeSetting,
+		Kind:        settings.KindInt,
+		Default:     "200",
+		Description: "Number of docs read at once when the rules run over the archive",
+		Validate:    settings.MinInt(1),
+	})
+}
+
+// Apply the rules to the docs. Every match lists the labels a rule
+// attaches which the doc doesn't carry yet. Nothing is changed in a dry
+// run. A label that can't be attached, e.g. because of an exclusive label
+// group, is reported in the error of the match and doesn't stop the run.
+func Apply(db *gorp.DbMap, rules []Rule, docList []docs.ExpandedDoc, text TextSource, dryRun bool) ([]Match, error) {
+	r := []Match{}
+
+	for _, doc := range docList {
+		doc := doc
+		has := map[int64]bool{}
+		for _, l := range doc.Labels {
+			has[l.ID] = true
+		}
+
+		// Read the text only once per doc and only if a rule needs it
+		var docText *string
+		readText := func() (string, error) {
+			if docText == nil {
+				t, err := text.Text(doc.Doc)
+				if err != nil {
+					return "", err
+				}
+				docText = &t
+			}
+			return *docText, nil
+		}
+
+		for _, rule := range rules {
+			ok, err := rule.Match(doc, readText)
+			if err != nil {
+				return []Match{}, err
+			}
+			if !ok {
+				continue
+			}
+
+			m := Match{
+				DocID:    doc.ID,
+				RuleID:   rule.ID,
+				LabelIDs: []int64{},
+			}
+			for _, id := range rule.LabelIDs {
+				if !has[id] {
+					m.LabelIDs = append(m.LabelIDs, id)
+				}
+			}
+			if len(m.LabelIDs) == 0 {
+				continue
+			}
+
+			if err := attach(db, m, dryRun); err != nil {
+				m.Error = err.Error()
+			} else {
+				for _, id := range m.LabelIDs {
+					has[id] = true
+				}
+			}
+
+			r = append(r, m)
+		}
+	}
+
+	return r, nil
+}
+
+func attach(db *gorp.DbMap, m Match, dryRun bool) error {
+	if dryRun {
+		for _, id := range m.LabelIDs {
+			if err := labels.CheckExclusiveGroup(db, m.DocID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	_, err := docs.AttachLabels(db, docs.BulkLabelsForm{
+		DocIDs:   []int64{m.DocID},
+		LabelIDs: m.LabelIDs,
+	})
+	return err
+}
+
+// Run the rules over all docs of the archive
+func RunRules(db *gorp.DbMap, rules []Rule, text TextSource, dryRun bool) (RunResult, error) {
+	r := RunResult{
+		DryRun:  dryRun,
+		Matches: []Match{},
+	}
+
+	batchSize, err := settings.Int(db, BatchSizeSetting)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	q := Q("SELECT * FROM %v WHERE id > ? ORDER BY id LIMIT ?", docs.DocsTable)
+	lastID := int64(0)
+	for {
+		docList := []docs.Doc{}
+		if _, err := db.Select(&docList, q, lastID, batchSize); err != nil {
+			return RunResult{}, err
+		}
+		if len(docList) == 0 {
+			break
+		}
+		lastID = docList[len(docList)-1].ID
+		r.Docs += len(docList)
+
+		expanded, err := docs.ExpandDocs(db, docList)
+		if err != nil {
+			return RunResult{}, err
+		}
+
+		m, err := Apply(db, rules, expanded, text, dryRun)
+		if err != nil {
+			return RunResult{}, err
+		}
+		r.Matches = append(r.Matches, m...)
+	}
+
+	return r, nil
+}
+
+var setupOnce sync.Once
+
+// Apply the enabled rules to every doc created from now on. The text of
+// the docs is read from their sidecar files in the files directory. Call
+// it once when the server starts, later calls do nothing.
+func Setup(files string) {
+	setupOnce.Do(func() {
+		docs.AddCreateHook(CreateHook(SidecarText{Dir: files}))
+	})
+}
+
+// Create a hook which applies all enabled rules to new docs, register it
+// with docs.AddCreateHook
+func CreateHook(text TextSource) docs.CreateHook {
+	return func(db *gorp.DbMap, doc docs.Doc) error {
+		rules, err := ReadEnabledRules(db)
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+
+		expanded, err := docs.ExpandDocs(db, []docs.Doc{doc})
+		if err != nil {
+			return err
+		}
+
+		_, err = Apply(db, rules, expanded, text, false)
+		return err
+	}
+}
@@ -0,0 +1,170 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/tochti/docMa-handler/accountingData"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"gopkg.in/gorp.v1"
+)
+
+func Test_RunRules(t *testing.T) {
+	db := initDB(t)
+
+	err := db.Insert(
+		&docs.Doc{ID: 1, Name: "strato_2016.pdf", Barcode: "1"},
+		&docs.Doc{ID: 2, Name: "miete.pdf", Barcode: "2"},
+		&docs.Doc{ID: 3, Name: "strato_2015.pdf", Barcode: "3"},
+		&labels.Label{ID: 1, Name: "Hosting"},
+		&docs.DocsLabels{DocID: 3, LabelID: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := Rule{
+		ID:         1,
+		Name:       "Hosting",
+		Enabled:    true,
+		Conditions: []Condition{{Field: FieldName, Op: OpContains, Value: "strato"}},
+		LabelIDs:   []int64{1},
+	}
+
+	r, err := RunRules(db, []Rule{rule}, SidecarText{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := RunResult{
+		DryRun:  true,
+		Docs:    3,
+		Matches: []Match{{DocID: 1, RuleID: 1, LabelIDs: []int64{1}}},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	l, err := docs.FindLabelsOfDoc(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 0 {
+		t.Fatalf("Expect no labels after dry run was %v", l)
+	}
+
+	r, err = RunRules(db, []Rule{rule}, SidecarText{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect.DryRun = false
+	if !reflect.DeepEqual(expect, r) {
+		t.Fatalf("Expect %v was %v", expect, r)
+	}
+
+	l, err = docs.FindLabelsOfDoc(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].ID != 1 {
+		t.Fatalf("Expect label 1 was %v", l)
+	}
+}
+
+func Test_CreateHook(t *testing.T) {
+	db := initDB(t)
+
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "scan.txt"), []byte("Mietvertrag"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := Rule{
+		Name:       "Miete",
+		Enabled:    true,
+		Conditions: []Condition{{Field: FieldText, Op: OpContains, Value: "miet"}},
+		LabelIDs:   []int64{1},
+	}
+	if err := db.Insert(&labels.Label{ID: 1, Name: "Miete"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveRule(db, &rule); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hook runs for every created doc
+	Setup(dir)
+	doc := docs.Doc{ID: 1, Name: "scan.pdf", Barcode: "1"}
+	if err := docs.CreateDoc(db, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := docs.FindLabelsOfDoc(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].ID != 1 {
+		t.Fatalf("Expect label 1 was %v", l)
+	}
+}
+
+func Test_SidecarText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sub := path.Join(dir, "files")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(sub, "scan.txt"), []byte("Mietvertrag"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := SidecarText{Dir: sub}
+	text, err := s.Text(docs.Doc{Name: "scan.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Mietvertrag" {
+		t.Fatalf("Expect Mietvertrag was %v", text)
+	}
+
+	// Names can't point outside of the directory
+	text, err = s.Text(docs.Doc{Name: "../secret.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "" {
+		t.Fatalf("Expect no text was %v", text)
+	}
+}
+
+func initDB(t *testing.T) *gorp.DbMap {
+	return common.InitTestDB(t,
+		AddTables,
+		docs.AddTables,
+		labels.AddTables,
+		accountingData.AddTables,
+		dbVars.AddTables,
+	)
+}
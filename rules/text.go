@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tochti/docMa-handler/docs"
+)
+
+// Source of the extracted text of a doc for conditions on the field text
+type TextSource interface {
+	Text(doc docs.Doc) (string, error)
+}
+
+// Read the text from a file next to the doc file with the extension .txt,
+// e.g. darkmoon.pdf has the text darkmoon.txt. Docs without such a file
+// have no text.
+type SidecarText struct {
+	Dir string
+}
+
+func (s SidecarText) Text(doc docs.Doc) (string, error) {
+	// Only files in the directory, the name can be set by clients
+	name := path.Base(doc.Name)
+	name = strings.TrimSuffix(name, path.Ext(name)) + ".txt"
+	b, err := ioutil.ReadFile(path.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,72 @@
+package savedSearches
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tochti/docMa-handler/docs"
+)
+
+var (
+	// First month of the fiscal year
+	FiscalYearStart = time.January
+
+	lastDaysRe = regexp.MustCompile(`^last_(\d+)_days$`)
+)
+
+// Resolve a relative date expression to an interval relative to now.
+// Known expressions are today, yesterday, current_month, last_month,
+// current_year, last_year, current_fiscal_year, last_fiscal_year and
+// last_<n>_days. Spaces are treated like underscores.
+func ResolveDateExpr(expr string, now time.Time) (docs.Interval, error) {
+	expr = strings.Replace(strings.ToLower(strings.TrimSpace(expr)), " ", "_", -1)
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+
+	fiscalYear := time.Date(now.Year(), FiscalYearStart, 1, 0, 0, 0, 0, now.Location())
+	if fiscalYear.After(now) {
+		fiscalYear = fiscalYear.AddDate(-1, 0, 0)
+	}
+
+	switch expr {
+	case "today":
+		return interval(today, today.AddDate(0, 0, 1)), nil
+	case "yesterday":
+		return interval(today.AddDate(0, 0, -1), today), nil
+	case "current_month":
+		return interval(month, month.AddDate(0, 1, 0)), nil
+	case "last_month":
+		return interval(month.AddDate(0, -1, 0), month), nil
+	case "current_year":
+		return interval(year, year.AddDate(1, 0, 0)), nil
+	case "last_year":
+		return interval(year.AddDate(-1, 0, 0), year), nil
+	case "current_fiscal_year":
+		return interval(fiscalYear, fiscalYear.AddDate(1, 0, 0)), nil
+	case "last_fiscal_year":
+		return interval(fiscalYear.AddDate(-1, 0, 0), fiscalYear), nil
+	}
+
+	if m := lastDaysRe.FindStringSubmatch(expr); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return docs.Interval{}, err
+		}
+		return interval(today.AddDate(0, 0, -n+1), today.AddDate(0, 0, 1)), nil
+	}
+
+	return docs.Interval{}, fmt.Errorf("unknown date expression %v", expr)
+}
+
+// Interval from start including to end excluding
+func interval(start, end time.Time) docs.Interval {
+	return docs.Interval{
+		From: start,
+		To:   end.Add(-time.Second),
+	}
+}
@@ -0,0 +1,68 @@
+package savedSearches
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ResolveDateExpr(t *testing.T) {
+	now := time.Date(2016, time.March, 15, 12, 0, 0, 0, time.UTC)
+	FiscalYearStart = time.July
+	defer func() { FiscalYearStart = time.January }()
+
+	tests := []struct {
+		expr string
+		from time.Time
+		to   time.Time
+	}{
+		{
+			"today",
+			time.Date(2016, time.March, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.March, 15, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"last month",
+			time.Date(2016, time.February, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.February, 29, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"current_year",
+			time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.December, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"Current Fiscal Year",
+			time.Date(2015, time.July, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.June, 30, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"last_fiscal_year",
+			time.Date(2014, time.July, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2015, time.June, 30, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			"last_7_days",
+			time.Date(2016, time.March, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.March, 15, 23, 59, 59, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		r, err := ResolveDateExpr(test.expr, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !r.From.Equal(test.from) || !r.To.Equal(test.to) {
+			t.Fatalf("Expect %v %v - %v was %v - %v",
+				test.expr, test.from, test.to, r.From, r.To)
+		}
+	}
+}
+
+func Test_ResolveDateExpr_Unknown(t *testing.T) {
+	_, err := ResolveDateExpr("next week", time.Now())
+	if err == nil {
+		t.Fatal("Expect error was nil")
+	}
+}
@@ -0,0 +1,102 @@
+package savedSearches
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tochti/docMa-handler/docs"
+	"gopkg.in/gorp.v1"
+)
+
+func AddTables(db *gorp.DbMap) {
+	tMap := db.AddTableWithName(SavedSearch{}, SavedSearchesTable).
+		SetKeys(true, "id")
+	tMap.ColMap("form").SetMaxSize(4096)
+}
+
+// Read a saved search which belongs to user or is shared
+func ReadSavedSearch(db *gorp.DbMap, id int64, user string) (SavedSearch, error) {
+	s := SavedSearch{}
+	q := Q(`
+	SELECT *
+	FROM %v
+	WHERE id=?
+	AND (owner=? OR shared=true)`, SavedSearchesTable)
+	if err := db.SelectOne(&s, q, id, user); err != nil {
+		return SavedSearch{}, err
+	}
+
+	if err := s.decodeForm(); err != nil {
+		return SavedSearch{}, err
+	}
+
+	return s, nil
+}
+
+// Read all saved searches of user and all shared searches
+func ReadAllSavedSearches(db *gorp.DbMap, user string) ([]SavedSearch, error) {
+	l := []SavedSearch{}
+	q := Q(`
+	SELECT *
+	FROM %v
+	WHERE owner=? OR shared=true
+	ORDER BY name, id`, SavedSearchesTable)
+	if _, err := db.Select(&l, q, user); err != nil {
+		return []SavedSearch{}, err
+	}
+
+	for i := range l {
+		if err := l[i].decodeForm(); err != nil {
+			return []SavedSearch{}, err
+		}
+	}
+
+	return l, nil
+}
+
+// Create the search form with all relative dates resolved for the given time
+func (s SavedSearch) Resolve(now time.Time) (docs.SearchForm, error) {
+	form := s.SearchForm
+
+	exprs := []struct {
+		expr     string
+		interval *docs.Interval
+	}{
+		{s.DateOfScan, &form.DateOfScan},
+		{s.DateOfReceipt, &form.DateOfReceipt},
+		{s.AccountPeriod, &form.AccountPeriod},
+	}
+
+	for _, e := range exprs {
+		if e.expr == "" {
+			continue
+		}
+
+		i, err := ResolveDateExpr(e.expr, now)
+		if err != nil {
+			return docs.SearchForm{}, err
+		}
+		*e.interval = i
+	}
+
+	return form, nil
+}
+
+func (s *SavedSearch) encodeForm() error {
+	b, err := json.Marshal(s.SearchForm)
+	if err != nil {
+		return err
+	}
+
+	s.Form = string(b)
+	return nil
+}
+
+func (s *SavedSearch) decodeForm() error {
+	return json.Unmarshal([]byte(s.Form), &s.SearchForm)
+}
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
@@ -0,0 +1,196 @@
+package savedSearches
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/valid"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	ErrNotOwner = errors.New("saved search belongs to another user")
+)
+
+func CreateSavedSearchHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	s := SavedSearch{}
+	if err := ginCtx.BindJSON(&s); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	owner, err := ReadOwner(ginCtx)
+	if err != nil {
+		return
+	}
+
+	s.ID = 0
+	s.Owner = owner
+	if err := valid.Struct(s); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	// Check that all date expressions are known
+	if _, err := s.Resolve(time.Now()); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.encodeForm(); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := db.Insert(&s); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusCreated, s)
+}
+
+func ReadAllSavedSearchesHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	owner, err := ReadOwner(ginCtx)
+	if err != nil {
+		return
+	}
+
+	l, err := ReadAllSavedSearches(db, owner)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+func ReadOneSavedSearchHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadSavedSearchID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	owner, err := ReadOwner(ginCtx)
+	if err != nil {
+		return
+	}
+
+	s, err := ReadSavedSearch(db, id, owner)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, s)
+}
+
+func DeleteSavedSearchHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadSavedSearchID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	user, err := ReadOwner(ginCtx)
+	if err != nil {
+		return
+	}
+
+	s, err := ReadSavedSearch(db, id, user)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	if s.Owner != user {
+		gumrest.ErrorResponse(ginCtx, http.StatusForbidden, ErrNotOwner)
+		return
+	}
+
+	if _, err := db.Delete(&s); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, nil)
+}
+
+// Run a saved search, the query parameters of common.ReadPage are supported
+func RunSavedSearchHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	id, err := ReadSavedSearchID(ginCtx)
+	if err != nil {
+		return
+	}
+
+	page, err := common.ReadPage(ginCtx)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	owner, err := ReadOwner(ginCtx)
+	if err != nil {
+		return
+	}
+
+	s, err := ReadSavedSearch(db, id, owner)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	searchForm, err := s.Resolve(time.Now())
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	r, total, err := docs.SearchDocs(db, searchForm, page)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, total)
+	ginCtx.JSON(http.StatusOK, r)
+}
+
+func ReadSavedSearchID(c *gin.Context) (int64, error) {
+	tmp := c.Params.ByName("searchID")
+	id, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil {
+		gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// User of the request, saved searches of anonymous requests would be
+// shared by all of them
+func ReadOwner(c *gin.Context) (string, error) {
+	user, err := common.RequireUser(c)
+	if err != nil {
+		gumrest.ErrorResponse(c, http.StatusUnauthorized, err)
+		return "", err
+	}
+
+	return user, nil
+}
+
+func errorResponse(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+		return
+	}
+
+	gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+}
@@ -0,0 +1,187 @@
+package savedSearches
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/gorp.v1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/docs"
+	"github.com/tochti/docMa-handler/labels"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+)
+
+func Test_CreateSavedSearchHandler(t *testing.T) {
+	db := initDB(t)
+
+	body := `
+	{
+		"name": "Miete",
+		"date_of_scan": "current_year",
+		"search_form": {"labels": "Miete"}
+	}
+	`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateSavedSearchHandler, db))
+	resp := serveAs(t, r, "karl", "POST", "/", body)
+
+	expect := SavedSearch{
+		ID:         1,
+		Owner:      "karl",
+		Name:       "Miete",
+		DateOfScan: "current_year",
+		SearchForm: docs.SearchForm{Labels: "Miete"},
+	}
+	expectResp := gumtest.JSONResponse{http.StatusCreated, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CreateSavedSearchHandler_UnknownDateExpr(t *testing.T) {
+	db := initDB(t)
+
+	body := `
+	{
+		"name": "Miete",
+		"date_of_scan": "next week"
+	}
+	`
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateSavedSearchHandler, db))
+	resp := serveAs(t, r, "karl", "POST", "/", body)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expect %v was %v", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func Test_ReadAllSavedSearches(t *testing.T) {
+	db := initDB(t)
+
+	insertSavedSearch(t, db, SavedSearch{Name: "mine", Owner: "karl"})
+	insertSavedSearch(t, db, SavedSearch{Name: "shared", Owner: "rosa", Shared: true})
+	insertSavedSearch(t, db, SavedSearch{Name: "private", Owner: "rosa"})
+
+	r, err := ReadAllSavedSearches(db, "karl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 2 || r[0].Name != "mine" || r[1].Name != "shared" {
+		t.Fatalf("Expect mine and shared was %v", r)
+	}
+}
+
+func Test_RunSavedSearchHandler(t *testing.T) {
+	db := initDB(t)
+
+	now := time.Now()
+	doc1 := docs.Doc{
+		ID:         1,
+		Name:       "FindMe.pdf",
+		Barcode:    "1",
+		DateOfScan: now,
+	}
+	doc2 := docs.Doc{
+		ID:         2,
+		Name:       "DontFindMe.pdf",
+		Barcode:    "2",
+		DateOfScan: now.AddDate(-2, 0, 0),
+	}
+	label := labels.Label{ID: 1, Name: "Miete"}
+	if err := db.Insert(&doc1, &doc2, &label,
+		&docs.DocsLabels{DocID: 1, LabelID: 1},
+		&docs.DocsLabels{DocID: 2, LabelID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	insertSavedSearch(t, db, SavedSearch{
+		Name:       "Miete",
+		Owner:      "karl",
+		DateOfScan: "current_year",
+		SearchForm: docs.SearchForm{Labels: "Miete"},
+	})
+
+	r := gin.New()
+	r.GET("/:searchID", gumwrap.Gorp(RunSavedSearchHandler, db))
+	resp := serveAs(t, r, "karl", "GET", "/1", "")
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expect %v was %v", http.StatusOK, resp.Code)
+	}
+
+	result := []docs.Doc{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].ID != doc1.ID {
+		t.Fatalf("Expect %v was %v", doc1, result)
+	}
+}
+
+func Test_SavedSearchHandlers_NoUser(t *testing.T) {
+	db := initDB(t)
+
+	insertSavedSearch(t, db, SavedSearch{Name: "anonymous"})
+
+	r := gin.New()
+	r.POST("/", gumwrap.Gorp(CreateSavedSearchHandler, db))
+	r.GET("/", gumwrap.Gorp(ReadAllSavedSearchesHandler, db))
+	r.DELETE("/:searchID", gumwrap.Gorp(DeleteSavedSearchHandler, db))
+
+	for _, c := range []struct{ method, url, body string }{
+		{"POST", "/", `{"name": "Miete"}`},
+		{"GET", "/", ""},
+		{"DELETE", "/1", ""},
+	} {
+		resp := gumtest.NewRouter(r).ServeHTTP(c.method, c.url, c.body)
+		if resp.Code != http.StatusUnauthorized {
+			t.Fatalf("%v %v: expect %v was %v", c.method, c.url, http.StatusUnauthorized, resp.Code)
+		}
+	}
+
+	n, err := db.SelectInt("SELECT COUNT(*) FROM " + SavedSearchesTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Expect 1 saved search was %v", n)
+	}
+}
+
+func serveAs(t *testing.T, r *gin.Engine, user, method, url, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(common.UserHeader, user)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func insertSavedSearch(t *testing.T, db *gorp.DbMap, s SavedSearch) {
+	if err := s.encodeForm(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Insert(&s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func initDB(t *testing.T) *gorp.DbMap {
+	return common.InitTestDB(t, AddTables, docs.AddTables, labels.AddTables)
+}
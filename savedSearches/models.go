@@ -0,0 +1,23 @@
+package savedSearches
+
+import "github.com/tochti/docMa-handler/docs"
+
+var (
+	SavedSearchesTable = "saved_searches"
+)
+
+type SavedSearch struct {
+	ID     int64  `db:"id" json:"id"`
+	Name   string `db:"name" json:"name" valid:"required"`
+	Owner  string `db:"owner" json:"owner"`
+	Shared bool   `db:"shared" json:"shared"`
+	// Relative date expressions like "last_month", they replace the
+	// matching interval of the search form when the search is run
+	DateOfScan    string `db:"date_of_scan" json:"date_of_scan"`
+	DateOfReceipt string `db:"date_of_receipt" json:"date_of_receipt"`
+	AccountPeriod string `db:"account_period" json:"account_period"`
+
+	// JSON encoded SearchForm
+	Form       string          `db:"form" json:"-"`
+	SearchForm docs.SearchForm `db:"-" json:"search_form"`
+}
@@ -0,0 +1,67 @@
+package settings
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/gin-gum/gumrest"
+	"gopkg.in/gorp.v1"
+)
+
+func ReadAllSettingsHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	l, err := GetAll(db)
+	if err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.SetTotalCount(ginCtx, int64(len(l)))
+	ginCtx.JSON(http.StatusOK, l)
+}
+
+func ReadOneSettingHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	v, err := Get(db, ginCtx.Params.ByName("name"))
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, v)
+}
+
+func UpdateSettingHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	form := UpdateForm{}
+	if err := ginCtx.BindJSON(&form); err != nil {
+		gumrest.ErrorResponse(ginCtx, http.StatusBadRequest, err)
+		return
+	}
+
+	v, err := Set(db, ginCtx.Params.ByName("name"), form.Value)
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, v)
+}
+
+// Reset the setting to its default
+func DeleteSettingHandler(ginCtx *gin.Context, db *gorp.DbMap) {
+	v, err := Reset(db, ginCtx.Params.ByName("name"))
+	if err != nil {
+		errorResponse(ginCtx, err)
+		return
+	}
+
+	ginCtx.JSON(http.StatusOK, v)
+}
+
+func errorResponse(c *gin.Context, err error) {
+	if err == ErrUnknownSetting {
+		gumrest.ErrorResponse(c, http.StatusNotFound, err)
+		return
+	}
+
+	gumrest.ErrorResponse(c, http.StatusBadRequest, err)
+}
@@ -0,0 +1,36 @@
+package settings
+
+// Kinds of setting values, all values are stored as strings
+const (
+	KindString   = "string"
+	KindInt      = "int"
+	KindBool     = "bool"
+	KindDuration = "duration"
+	KindJSON     = "json"
+)
+
+type (
+	// Known setting with its default value. Validate is called with every
+	// new value after the value was checked against the kind. Volatile
+	// settings are changed within transactions, e.g. counters, and are
+	// always read from the db.
+	Setting struct {
+		Name        string             `json:"name"`
+		Kind        string             `json:"kind"`
+		Default     string             `json:"default"`
+		Description string             `json:"description"`
+		Volatile    bool               `json:"volatile"`
+		Validate    func(string) error `json:"-"`
+	}
+
+	// Setting together with its current value
+	SettingValue struct {
+		Setting
+		Value     string `json:"value"`
+		IsDefault bool   `json:"is_default"`
+	}
+
+	UpdateForm struct {
+		Value string `json:"value"`
+	}
+)
@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidJSON    = errors.New("invalid json")
+
+	registry   = map[string]Setting{}
+	registryMu sync.RWMutex
+)
+
+// Make a setting known, usually from the init function of the package
+// using it. Register panics if the name is taken or the default is invalid.
+func Register(s Setting) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[s.Name]; ok {
+		panic(fmt.Sprintf("setting %v registered twice", s.Name))
+	}
+
+	if err := s.check(s.Default); err != nil {
+		panic(fmt.Sprintf("invalid default of setting %v: %v", s.Name, err))
+	}
+
+	registry[s.Name] = s
+}
+
+// Remove a setting from the registry, only used by tests
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+func Lookup(name string) (Setting, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	s, ok := registry[name]
+	if !ok {
+		return Setting{}, ErrUnknownSetting
+	}
+
+	return s, nil
+}
+
+// All known settings ordered by name
+func All() []Setting {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	l := []Setting{}
+	for _, s := range registry {
+		l = append(l, s)
+	}
+	sort.Sort(byName(l))
+
+	return l
+}
+
+// Check that the value fits the kind of the setting and passes its
+// validation
+func (s Setting) check(value string) error {
+	var err error
+	switch s.Kind {
+	case KindString:
+	case KindInt:
+		_, err = strconv.Atoi(value)
+	case KindBool:
+		_, err = strconv.ParseBool(value)
+	case KindDuration:
+		_, err = time.ParseDuration(value)
+	case KindJSON:
+		if !json.Valid([]byte(value)) {
+			err = ErrInvalidJSON
+		}
+	default:
+		err = fmt.Errorf("unknown kind %v", s.Kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.Validate != nil {
+		return s.Validate(value)
+	}
+
+	return nil
+}
+
+// Validation of int settings which have to be at least min
+func MinInt(min int) func(string) error {
+	return func(v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if i < min {
+			return fmt.Errorf("must be at least %v", min)
+		}
+		return nil
+	}
+}
+
+// Validation of duration settings which have to be at least min
+func MinDuration(min time.Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if d < min {
+			return fmt.Errorf("must be at least %v", min)
+		}
+		return nil
+	}
+}
+
+type byName []Setting
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
@@ -0,0 +1,78 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_SettingCheck(t *testing.T) {
+	tests := []struct {
+		kind  string
+		value string
+		ok    bool
+	}{
+		{KindString, "", true},
+		{KindInt, "42", true},
+		{KindInt, "4.2", false},
+		{KindBool, "true", true},
+		{KindBool, "yes", false},
+		{KindDuration, "1h30m", true},
+		{KindDuration, "90", false},
+		{KindJSON, `{"a": [1, 2]}`, true},
+		{KindJSON, `{"a":`, false},
+		{"float", "1.0", false},
+	}
+
+	for _, test := range tests {
+		err := Setting{Kind: test.kind}.check(test.value)
+		if (err == nil) != test.ok {
+			t.Fatalf("Expect ok %v for %v %q was %v", test.ok, test.kind, test.value, err)
+		}
+	}
+}
+
+func Test_SettingCheck_Validate(t *testing.T) {
+	errTooSmall := errors.New("too small")
+	s := Setting{
+		Kind: KindInt,
+		Validate: func(v string) error {
+			if v == "0" {
+				return errTooSmall
+			}
+			return nil
+		},
+	}
+
+	if err := s.check("0"); err != errTooSmall {
+		t.Fatalf("Expect %v was %v", errTooSmall, err)
+	}
+}
+
+func Test_Register(t *testing.T) {
+	Register(Setting{Name: "test.register", Kind: KindInt, Default: "1"})
+	defer unregister("test.register")
+
+	s, err := Lookup("test.register")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Default != "1" {
+		t.Fatalf("Expect %v was %v", "1", s.Default)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expect panic for duplicate setting")
+		}
+	}()
+	Register(Setting{Name: "test.register", Kind: KindInt, Default: "1"})
+}
+
+func Test_Register_InvalidDefault(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expect panic for invalid default")
+		}
+	}()
+	Register(Setting{Name: "test.invalid", Kind: KindBool, Default: "maybe"})
+}
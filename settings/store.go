@@ -0,0 +1,270 @@
+package settings
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tochti/docMa-handler/dbVars"
+	"gopkg.in/gorp.v1"
+)
+
+var (
+	// Values read from or written to the db, per db. Values are stored
+	// by Set so readers in the same process never see an old value.
+	cache   = map[*gorp.DbMap]map[string]string{}
+	cacheMu sync.RWMutex
+)
+
+// Read the value of a known setting, the default is returned if the value
+// was never set
+func Get(db *gorp.DbMap, name string) (SettingValue, error) {
+	s, err := Lookup(name)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	v, ok, err := read(db, name)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	if !ok {
+		return SettingValue{Setting: s, Value: s.Default, IsDefault: true}, nil
+	}
+
+	return SettingValue{Setting: s, Value: v}, nil
+}
+
+// Read the values of all known settings
+func GetAll(db *gorp.DbMap) ([]SettingValue, error) {
+	l := []SettingValue{}
+	for _, s := range All() {
+		v, err := Get(db, s.Name)
+		if err != nil {
+			return []SettingValue{}, err
+		}
+		l = append(l, v)
+	}
+
+	return l, nil
+}
+
+// Check and store the value of a known setting
+func Set(db *gorp.DbMap, name, value string) (SettingValue, error) {
+	s, err := Lookup(name)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	if err := s.check(value); err != nil {
+		return SettingValue{}, err
+	}
+
+	v := dbVars.DBVar{Name: name, Value: value}
+	n, err := db.Update(&v)
+	if err != nil {
+		return SettingValue{}, err
+	}
+	if n == 0 {
+		// Update affects no rows if the value is unchanged, so check
+		// that the row exists before inserting it
+		exists, err := db.SelectInt(Q("SELECT COUNT(*) FROM %v WHERE name=?", dbVars.DBVarsTable), name)
+		if err != nil {
+			return SettingValue{}, err
+		}
+		if exists == 0 {
+			if err := db.Insert(&v); err != nil {
+				return SettingValue{}, err
+			}
+		}
+	}
+
+	store(db, name, value, true)
+
+	return SettingValue{Setting: s, Value: value}, nil
+}
+
+// Remove the stored value so the default is used again
+func Reset(db *gorp.DbMap, name string) (SettingValue, error) {
+	s, err := Lookup(name)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	q := Q("DELETE FROM %v WHERE name=?", dbVars.DBVarsTable)
+	if _, err := db.Exec(q, name); err != nil {
+		return SettingValue{}, err
+	}
+
+	store(db, name, "", false)
+
+	return SettingValue{Setting: s, Value: s.Default, IsDefault: true}, nil
+}
+
+// Drop all cached values of the db, e.g. after the db_vars table was
+// changed by someone else
+func Invalidate(db *gorp.DbMap) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	delete(cache, db)
+}
+
+func Int(db *gorp.DbMap, name string) (int, error) {
+	v, err := Get(db, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(v.Value)
+}
+
+func SetInt(db *gorp.DbMap, name string, i int) error {
+	_, err := Set(db, name, strconv.Itoa(i))
+	return err
+}
+
+func Bool(db *gorp.DbMap, name string) (bool, error) {
+	v, err := Get(db, name)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(v.Value)
+}
+
+func SetBool(db *gorp.DbMap, name string, b bool) error {
+	_, err := Set(db, name, strconv.FormatBool(b))
+	return err
+}
+
+func Duration(db *gorp.DbMap, name string) (time.Duration, error) {
+	v, err := Get(db, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.ParseDuration(v.Value)
+}
+
+func SetDuration(db *gorp.DbMap, name string, d time.Duration) error {
+	_, err := Set(db, name, d.String())
+	return err
+}
+
+// Decode the JSON value of the setting into v
+func JSON(db *gorp.DbMap, name string, v interface{}) error {
+	s, err := Get(db, name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(s.Value), v)
+}
+
+func SetJSON(db *gorp.DbMap, name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = Set(db, name, string(b))
+	return err
+}
+
+// Add one to an int setting within the transaction and return the new
+// value. The row is locked until the transaction is committed, so
+// concurrent callers never get the same value. Only volatile settings can
+// be incremented, the cache doesn't see the change.
+func IncrementTx(tx gorp.SqlExecutor, name string) (int, error) {
+	s, err := Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if s.Kind != KindInt || !s.Volatile {
+		return 0, fmt.Errorf("setting %v is no volatile int", name)
+	}
+
+	// Store the default first, so there is always a row to lock
+	q := Q("INSERT IGNORE INTO %v (name, value) VALUES (?, ?)", dbVars.DBVarsTable)
+	if _, err := tx.Exec(q, name, s.Default); err != nil {
+		return 0, err
+	}
+
+	v := dbVars.DBVar{}
+	q = Q("SELECT * FROM %v WHERE name=? FOR UPDATE", dbVars.DBVarsTable)
+	if err := tx.SelectOne(&v, q, name); err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(v.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	v.Value = strconv.Itoa(i + 1)
+	if err := s.check(v.Value); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Update(&v); err != nil {
+		return 0, err
+	}
+
+	return i + 1, nil
+}
+
+// Read a value from the cache or the db. The bool is false if the value
+// isn't stored.
+func read(db *gorp.DbMap, name string) (string, bool, error) {
+	s, err := Lookup(name)
+	if err != nil {
+		return "", false, err
+	}
+
+	cacheMu.RLock()
+	v, ok := cache[db][name]
+	cacheMu.RUnlock()
+	if ok && !s.Volatile {
+		return v, true, nil
+	}
+
+	dbVar := dbVars.DBVar{}
+	q := Q("SELECT * FROM %v WHERE name=?", dbVars.DBVarsTable)
+	err = db.SelectOne(&dbVar, q, name)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if !s.Volatile {
+		store(db, name, dbVar.Value, true)
+	}
+
+	return dbVar.Value, true, nil
+}
+
+func store(db *gorp.DbMap, name, value string, ok bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if !ok {
+		delete(cache[db], name)
+		return
+	}
+
+	if cache[db] == nil {
+		cache[db] = map[string]string{}
+	}
+	cache[db][name] = value
+}
+
+func Q(q string, p ...interface{}) string {
+	return fmt.Sprintf(q, p...)
+}
@@ -0,0 +1,176 @@
+package settings
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tochti/docMa-handler/common"
+	"github.com/tochti/docMa-handler/dbVars"
+	"github.com/tochti/gin-gum/gumtest"
+	"github.com/tochti/gin-gum/gumwrap"
+	"gopkg.in/gorp.v1"
+)
+
+func Test_SetGet(t *testing.T) {
+	db := initDB(t)
+
+	Register(Setting{Name: "test.interval", Kind: KindDuration, Default: "1m"})
+	defer unregister("test.interval")
+
+	d, err := Duration(db, "test.interval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != time.Minute {
+		t.Fatalf("Expect %v was %v", time.Minute, d)
+	}
+
+	if err := SetDuration(db, "test.interval", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Duration(db, "test.interval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != time.Hour {
+		t.Fatalf("Expect %v was %v", time.Hour, d)
+	}
+
+	v, err := db.Get(dbVars.DBVar{}, "test.interval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.(*dbVars.DBVar).Value != "1h0m0s" {
+		t.Fatalf("Expect %v was %v", "1h0m0s", v.(*dbVars.DBVar).Value)
+	}
+
+	if _, err := Set(db, "test.interval", "soon"); err == nil {
+		t.Fatal("Expect error for invalid duration")
+	}
+
+	if _, err := Reset(db, "test.interval"); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Duration(db, "test.interval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != time.Minute {
+		t.Fatalf("Expect %v was %v", time.Minute, d)
+	}
+}
+
+func Test_Get_Cache(t *testing.T) {
+	db := initDB(t)
+
+	Register(Setting{Name: "test.cache", Kind: KindInt, Default: "0"})
+	defer unregister("test.cache")
+
+	if err := SetInt(db, "test.cache", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changed behind the back of the cache
+	if _, err := db.Update(&dbVars.DBVar{Name: "test.cache", Value: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := Int(db, "test.cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 1 {
+		t.Fatalf("Expect %v was %v", 1, i)
+	}
+
+	Invalidate(db)
+
+	i, err = Int(db, "test.cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 2 {
+		t.Fatalf("Expect %v was %v", 2, i)
+	}
+}
+
+func Test_UpdateSettingHandler(t *testing.T) {
+	db := initDB(t)
+
+	Register(Setting{Name: "test.enabled", Kind: KindBool, Default: "false"})
+	defer unregister("test.enabled")
+
+	r := gin.New()
+	r.PUT("/:name", gumwrap.Gorp(UpdateSettingHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/test.enabled", `{"value": "true"}`)
+
+	s, _ := Lookup("test.enabled")
+	expect := SettingValue{Setting: s, Value: "true"}
+	expectResp := gumtest.JSONResponse{http.StatusOK, expect}
+	if err := gumtest.EqualJSONResponse(expectResp, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Bool(db, "test.enabled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b {
+		t.Fatalf("Expect %v was %v", true, b)
+	}
+}
+
+func Test_UpdateSettingHandler_Unknown(t *testing.T) {
+	db := initDB(t)
+
+	r := gin.New()
+	r.PUT("/:name", gumwrap.Gorp(UpdateSettingHandler, db))
+	resp := gumtest.NewRouter(r).ServeHTTP("PUT", "/unknown", `{"value": "1"}`)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expect %v was %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+func initDB(t *testing.T) *gorp.DbMap {
+	db := common.InitTestDB(t, dbVars.AddTables)
+	Invalidate(db)
+	return db
+}
+
+func Test_IncrementTx(t *testing.T) {
+	db := initDB(t)
+
+	Register(Setting{Name: "test.counter", Kind: KindInt, Default: "5", Volatile: true})
+	defer unregister("test.counter")
+
+	// Cache the value before it changes within the transaction
+	if i, err := Int(db, "test.counter"); err != nil || i != 5 {
+		t.Fatalf("Expect %v was %v %v", 5, i, err)
+	}
+
+	for _, expect := range []int{6, 7} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		i, err := IncrementTx(tx, "test.counter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if i != expect {
+			t.Fatalf("Expect %v was %v", expect, i)
+		}
+	}
+
+	if i, err := Int(db, "test.counter"); err != nil || i != 7 {
+		t.Fatalf("Expect %v was %v %v", 7, i, err)
+	}
+}